@@ -2,4 +2,14 @@ module github.com/ultraman/go-common
 
 go 1.16
 
-require github.com/rs/zerolog v1.24.0
+require (
+	github.com/klauspost/compress v1.13.6
+	github.com/prometheus/client_golang v1.11.0
+	github.com/rs/zerolog v1.24.0
+	github.com/vmihailenco/msgpack/v5 v5.3.5
+	go.opentelemetry.io/otel v1.0.1
+	go.opentelemetry.io/otel/sdk v1.0.1
+	go.opentelemetry.io/otel/trace v1.0.1
+	google.golang.org/protobuf v1.27.1
+	gopkg.in/yaml.v2 v2.4.0
+)
@@ -1,7 +1,10 @@
 package rest
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
+	"sync"
 )
 
 type AuthConfig struct {
@@ -14,6 +17,43 @@ type AuthProvider interface {
 	Login() error
 }
 
+// AuthProviderFactory 根据 AuthConfig.Config 构造 AuthProvider
+type AuthProviderFactory func(config map[string]string) (AuthProvider, error)
+
+var (
+	pluginsLock sync.Mutex
+	plugins     = make(map[string]AuthProviderFactory)
+)
+
+func init() {
+	_ = RegisterAuthProviderPlugin("basic", NewBasicAuthProvider)
+	_ = RegisterAuthProviderPlugin("bearer", NewBearerAuthProvider)
+	_ = RegisterAuthProviderPlugin("oauth2", NewOAuth2AuthProvider)
+	_ = RegisterAuthProviderPlugin("hmac", NewHMACAuthProvider)
+}
+
+// RegisterAuthProviderPlugin 注册 AuthProvider, 同名重复注册返回错误
+func RegisterAuthProviderPlugin(name string, plugin AuthProviderFactory) error {
+	pluginsLock.Lock()
+	defer pluginsLock.Unlock()
+	if _, found := plugins[name]; found {
+		return fmt.Errorf("auth provider plugin %q was registered twice", name)
+	}
+	plugins[name] = plugin
+	return nil
+}
+
+// GetAuthProvider 根据 AuthConfig.Name 查找并构造 AuthProvider, 构造时不持有锁
+func GetAuthProvider(config *AuthConfig) (AuthProvider, error) {
+	pluginsLock.Lock()
+	p, ok := plugins[config.Name]
+	pluginsLock.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("no auth provider found for name %q", config.Name)
+	}
+	return p(config.Config)
+}
+
 type nullAuthProvider struct {
 	rt http.RoundTripper
 }
@@ -23,7 +63,7 @@ func (p *nullAuthProvider) RoundTrip(request *http.Request) (*http.Response, err
 }
 
 func (p *nullAuthProvider) WrapTransport(rt http.RoundTripper) http.RoundTripper {
-	return p
+	return &nullAuthProvider{rt: rt}
 }
 
 func (p nullAuthProvider) Login() error {
@@ -33,3 +73,54 @@ func (p nullAuthProvider) Login() error {
 func NewNullAuthProvider() AuthProvider {
 	return &nullAuthProvider{}
 }
+
+type basicAuthProvider struct {
+	username string
+	password string `datapolicy:"password"`
+}
+
+// NewBasicAuthProvider 支持的配置: username, password
+func NewBasicAuthProvider(config map[string]string) (AuthProvider, error) {
+	if len(config["username"]) == 0 {
+		return nil, errors.New("basic: username is required")
+	}
+	return &basicAuthProvider{username: config["username"], password: config["password"]}, nil
+}
+
+func (p *basicAuthProvider) WrapTransport(rt http.RoundTripper) http.RoundTripper {
+	return NewBasicAuthRoundTripper(p.username, p.password, rt)
+}
+
+func (p *basicAuthProvider) Login() error {
+	return nil
+}
+
+type bearerAuthProvider struct {
+	token  string `datapolicy:"token"`
+	source *tokenFileSource
+}
+
+// NewBearerAuthProvider 支持的配置: token, token_file
+func NewBearerAuthProvider(config map[string]string) (AuthProvider, error) {
+	p := &bearerAuthProvider{token: config["token"]}
+	if file := config["token_file"]; len(file) != 0 {
+		p.source = newTokenFileSource(file, TokenFileRefreshPeriod)
+	}
+	if len(p.token) == 0 && p.source == nil {
+		return nil, errors.New("bearer: token or token_file is required")
+	}
+	return p, nil
+}
+
+func (p *bearerAuthProvider) WrapTransport(rt http.RoundTripper) http.RoundTripper {
+	return &bearerAuthRoundTripper{bearer: p.token, source: p.source, rt: rt}
+}
+
+// Login 使用 token 文件时检查文件是否可读
+func (p *bearerAuthProvider) Login() error {
+	if p.source == nil {
+		return nil
+	}
+	_, err := p.source.Token()
+	return err
+}
@@ -0,0 +1,178 @@
+package rest
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type countingAuthProvider struct {
+	logins int
+}
+
+func (p *countingAuthProvider) WrapTransport(rt http.RoundTripper) http.RoundTripper {
+	return NewBearerAuthRoundTripper("custom", rt)
+}
+
+func (p *countingAuthProvider) Login() error {
+	p.logins++
+	return nil
+}
+
+func TestRegisterAuthProviderPlugin(t *testing.T) {
+	provider := &countingAuthProvider{}
+	err := RegisterAuthProviderPlugin("test-counting", func(config map[string]string) (AuthProvider, error) {
+		return provider, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := RegisterAuthProviderPlugin("test-counting", nil); err == nil {
+		t.Fatal("expected duplicate registration to be rejected")
+	}
+	if _, err := GetAuthProvider(&AuthConfig{Name: "unknown"}); err == nil {
+		t.Fatal("expected unknown provider to be rejected")
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(r.Header.Get("Authorization")))
+	}))
+	defer server.Close()
+
+	client, err := NewRESTClientFor(&Config{Host: server.URL, AuthConfig: AuthConfig{Name: "test-counting"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if provider.logins != 1 {
+		t.Fatalf("expected Login to be called once, got %d", provider.logins)
+	}
+	body, _ := client.Get().Path("/").DoRaw(context.Background())
+	if string(body) != "Bearer custom" {
+		t.Fatalf("unexpected Authorization %q", body)
+	}
+}
+
+func TestAuthConfig_BuiltinProviders(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(r.Header.Get("Authorization")))
+	}))
+	defer server.Close()
+
+	cases := []struct {
+		config   AuthConfig
+		expected string
+	}{
+		{AuthConfig{Name: "basic", Config: map[string]string{"username": "user", "password": "pass"}}, "Basic dXNlcjpwYXNz"},
+		{AuthConfig{Name: "bearer", Config: map[string]string{"token": "token"}}, "Bearer token"},
+	}
+	for _, c := range cases {
+		client, err := NewRESTClientFor(&Config{Host: server.URL, AuthConfig: c.config})
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := client.Get().Path("/").DoRaw(context.Background())
+		if string(body) != c.expected {
+			t.Errorf("%s: expected %q, got %q", c.config.Name, c.expected, body)
+		}
+	}
+
+	if _, err := NewRESTClientFor(&Config{Host: server.URL, AuthConfig: AuthConfig{Name: "basic"}}); err == nil {
+		t.Fatal("expected basic provider without username to be rejected")
+	}
+	bearer := AuthConfig{Name: "bearer", Config: map[string]string{"token_file": "/nonexistent/token"}}
+	if _, err := NewRESTClientFor(&Config{Host: server.URL, AuthConfig: bearer}); err == nil {
+		t.Fatal("expected Login to fail for a missing token file")
+	}
+}
+
+func TestAuthConfig_OAuth2(t *testing.T) {
+	server := newOAuth2TestServer(t, 3600)
+	defer server.Close()
+
+	client, err := NewRESTClientFor(&Config{
+		Host: server.URL,
+		AuthConfig: AuthConfig{Name: "oauth2", Config: map[string]string{
+			"token_url":     server.URL + "/token",
+			"client_id":     "client",
+			"client_secret": "secret",
+			"scopes":        "read write",
+		}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if server.tokens != 1 {
+		t.Fatalf("expected token to be fetched on login, fetched %d tokens", server.tokens)
+	}
+	body, err := client.Post().Path("/").Body([]byte("hello")).DoRaw(context.Background())
+	if err != nil || string(body) != "hello" {
+		t.Fatalf("unexpected response %q %v", body, err)
+	}
+}
+
+func TestGetAuthProvider_NestedFactory(t *testing.T) {
+	// 工厂中可以查找其他 AuthProvider, 不会死锁
+	err := RegisterAuthProviderPlugin("test-nested", func(config map[string]string) (AuthProvider, error) {
+		return GetAuthProvider(&AuthConfig{Name: "basic", Config: config})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan error, 1)
+	go func() {
+		_, err := GetAuthProvider(&AuthConfig{Name: "test-nested", Config: map[string]string{"username": "user"}})
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("GetAuthProvider deadlocked")
+	}
+}
+
+func TestConfig_TransportConfigDoesNotLogin(t *testing.T) {
+	provider := &countingAuthProvider{}
+	err := RegisterAuthProviderPlugin("test-login-once", func(config map[string]string) (AuthProvider, error) {
+		return provider, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := &Config{Host: "http://localhost", AuthConfig: AuthConfig{Name: "test-login-once"}}
+	for i := 0; i < 3; i++ {
+		if _, err := config.TransportConfig(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if provider.logins != 0 {
+		t.Fatalf("expected TransportConfig not to login, got %d logins", provider.logins)
+	}
+	if _, err := NewRESTClientFor(config); err != nil {
+		t.Fatal(err)
+	}
+	if provider.logins != 1 {
+		t.Fatalf("expected a single login when building the client, got %d", provider.logins)
+	}
+}
+
+func TestHTTPWrappersForConfig_ValidateBeforeLogin(t *testing.T) {
+	provider := &countingAuthProvider{}
+	config := &TransportConfig{
+		Username:          "user",
+		Password:          "pass",
+		BearerToken:       "token",
+		AuthProvider:      provider,
+		loginAuthProvider: true,
+	}
+	if _, err := HTTPWrappersForConfig(config, http.DefaultTransport); err == nil {
+		t.Fatal("expected basic auth with bearer token to be rejected")
+	}
+	if provider.logins != 0 {
+		t.Fatalf("expected invalid config to fail before login, got %d logins", provider.logins)
+	}
+}
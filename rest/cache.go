@@ -0,0 +1,468 @@
+package rest
+
+import (
+	"bufio"
+	"bytes"
+	"container/list"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httputil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// CacheStore 缓存响应的存储, 实现需要支持并发调用
+type CacheStore interface {
+	Get(key string) ([]byte, bool)
+	Set(key string, value []byte)
+	Delete(key string)
+}
+
+// lruCacheStore 内存 LRU, 超过 maxEntries 时淘汰最久未使用的响应
+type lruCacheStore struct {
+	maxEntries int
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	order   *list.List
+}
+
+type lruEntry struct {
+	key   string
+	value []byte
+}
+
+// NewLRUCacheStore maxEntries <= 0 时不限制数量
+func NewLRUCacheStore(maxEntries int) CacheStore {
+	return &lruCacheStore{
+		maxEntries: maxEntries,
+		entries:    make(map[string]*list.Element),
+		order:      list.New(),
+	}
+}
+
+func (s *lruCacheStore) Get(key string) ([]byte, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	element, ok := s.entries[key]
+	if !ok {
+		return nil, false
+	}
+	s.order.MoveToFront(element)
+	return element.Value.(*lruEntry).value, true
+}
+
+func (s *lruCacheStore) Set(key string, value []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if element, ok := s.entries[key]; ok {
+		element.Value.(*lruEntry).value = value
+		s.order.MoveToFront(element)
+		return
+	}
+	s.entries[key] = s.order.PushFront(&lruEntry{key: key, value: value})
+	if s.maxEntries > 0 && s.order.Len() > s.maxEntries {
+		oldest := s.order.Back()
+		s.order.Remove(oldest)
+		delete(s.entries, oldest.Value.(*lruEntry).key)
+	}
+}
+
+func (s *lruCacheStore) Delete(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if element, ok := s.entries[key]; ok {
+		s.order.Remove(element)
+		delete(s.entries, key)
+	}
+}
+
+// diskCacheStore 每个响应一个文件, 文件名为 key 的 md5
+type diskCacheStore struct {
+	dir string
+}
+
+// NewDiskCacheStore dir 不存在时创建, 多个进程可以共享同一个目录
+func NewDiskCacheStore(dir string) (CacheStore, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	return &diskCacheStore{dir: dir}, nil
+}
+
+func (s *diskCacheStore) path(key string) string {
+	return filepath.Join(s.dir, md5Util(key))
+}
+
+func (s *diskCacheStore) Get(key string) ([]byte, bool) {
+	data, err := ioutil.ReadFile(s.path(key))
+	if err != nil {
+		return nil, false
+	}
+	return data, true
+}
+
+// Set 先写入临时文件再重命名, 读取时不会看到写了一半的文件
+func (s *diskCacheStore) Set(key string, value []byte) {
+	file, err := ioutil.TempFile(s.dir, ".tmp-")
+	if err != nil {
+		return
+	}
+	_, err = file.Write(value)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(file.Name(), s.path(key))
+	}
+	if err != nil {
+		_ = os.Remove(file.Name())
+	}
+}
+
+func (s *diskCacheStore) Delete(key string) {
+	_ = os.Remove(s.path(key))
+}
+
+// CacheStats 缓存命中统计
+type CacheStats struct {
+	// Hits 直接使用缓存, 没有发送请求
+	Hits int64
+	// Misses 没有可用的缓存
+	Misses int64
+	// Revalidations 发送了条件请求, NotModified 为其中返回 304 的次数
+	Revalidations int64
+	NotModified   int64
+}
+
+const (
+	// cacheStoredAtHeader 与 cacheVariedHeader 仅存在于存储中
+	cacheStoredAtHeader = "X-Rest-Cache-Stored-At"
+	cacheVariedHeader   = "X-Rest-Cache-Varied-"
+	// CacheHeader 来自缓存的响应会带上该响应头, 值为 HIT 或 REVALIDATED
+	CacheHeader = "X-From-Cache"
+	// DefaultCacheMaxBodySize 超过该大小的响应体不缓存
+	DefaultCacheMaxBodySize = 8 << 20
+)
+
+// HTTPCache 遵循 Cache-Control, Expires, ETag 与 Last-Modified 的客户端缓存
+// 只缓存 GET 的 200 响应, 同一地址的其他请求成功后会删除缓存
+// 缓存以地址为 key, 可能被多个用户共享, 不缓存 private 响应,
+// 带有 Authorization 的请求只缓存 public, s-maxage 或 must-revalidate 的响应 (RFC 9111 3.5)
+// 每个地址只保存一个 Vary 变体, 请求头与缓存的变体不一致时删除旧的缓存
+type HTTPCache struct {
+	// 原子操作的计数放在结构体开头, 保证 32 位平台上 8 字节对齐
+	hits          int64
+	misses        int64
+	revalidations int64
+	notModified   int64
+
+	store CacheStore
+	// MaxBodySize 默认为 DefaultCacheMaxBodySize
+	MaxBodySize int64
+	// Now 默认为 time.Now
+	Now func() time.Time
+}
+
+func NewHTTPCache(store CacheStore) *HTTPCache {
+	return &HTTPCache{
+		store:       store,
+		MaxBodySize: DefaultCacheMaxBodySize,
+		Now:         time.Now,
+	}
+}
+
+// WrapTransport 可以作为 Config.WrapTransport 使用
+func (c *HTTPCache) WrapTransport(rt http.RoundTripper) http.RoundTripper {
+	return &cacheRoundTripper{cache: c, rt: rt}
+}
+
+func (c *HTTPCache) Stats() CacheStats {
+	return CacheStats{
+		Hits:          atomic.LoadInt64(&c.hits),
+		Misses:        atomic.LoadInt64(&c.misses),
+		Revalidations: atomic.LoadInt64(&c.revalidations),
+		NotModified:   atomic.LoadInt64(&c.notModified),
+	}
+}
+
+type cacheRoundTripper struct {
+	cache *HTTPCache
+	rt    http.RoundTripper
+}
+
+func (rt *cacheRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	c := rt.cache
+	key := req.URL.String()
+	switch req.Method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		resp, err := rt.rt.RoundTrip(req)
+		if err == nil && resp.StatusCode < http.StatusBadRequest {
+			c.store.Delete(key)
+		}
+		return resp, err
+	}
+	reqControl := parseCacheControl(req.Header)
+	if req.Method != http.MethodGet || reqControl.has("no-store") || len(req.Header.Get("Range")) > 0 {
+		return rt.rt.RoundTrip(req)
+	}
+
+	cached, storedAt := c.load(key, req)
+	if cached == nil {
+		atomic.AddInt64(&c.misses, 1)
+		resp, err := rt.rt.RoundTrip(req)
+		if err != nil {
+			return nil, err
+		}
+		return c.storeOnRead(key, req, resp), nil
+	}
+
+	if c.fresh(cached, storedAt, reqControl) {
+		atomic.AddInt64(&c.hits, 1)
+		cached.Header.Set(CacheHeader, "HIT")
+		return cached, nil
+	}
+
+	etag, lastModified := cached.Header.Get("ETag"), cached.Header.Get("Last-Modified")
+	if len(etag) == 0 && len(lastModified) == 0 {
+		_ = cached.Body.Close()
+		atomic.AddInt64(&c.misses, 1)
+		resp, err := rt.rt.RoundTrip(req)
+		if err != nil {
+			return nil, err
+		}
+		return c.storeOnRead(key, req, resp), nil
+	}
+
+	// 使用 ETag 或 Last-Modified 重新验证, 调用方已经设置条件请求头时不覆盖
+	atomic.AddInt64(&c.revalidations, 1)
+	conditional := CloneRequest(req)
+	if len(etag) > 0 && len(req.Header.Get("If-None-Match")) == 0 {
+		conditional.Header.Set("If-None-Match", etag)
+	}
+	if len(lastModified) > 0 && len(req.Header.Get("If-Modified-Since")) == 0 {
+		conditional.Header.Set("If-Modified-Since", lastModified)
+	}
+	resp, err := rt.rt.RoundTrip(conditional)
+	if err != nil {
+		_ = cached.Body.Close()
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusNotModified || !ownsConditional(req) {
+		_ = cached.Body.Close()
+		return c.storeOnRead(key, req, resp), nil
+	}
+
+	// 304: 使用新的响应头更新缓存并返回缓存的响应体
+	atomic.AddInt64(&c.notModified, 1)
+	readAndCloseResponseBody(resp)
+	for name, values := range resp.Header {
+		switch name {
+		case "Content-Length", "Content-Encoding", "Transfer-Encoding":
+			continue
+		}
+		cached.Header[name] = values
+	}
+	body, err := ioutil.ReadAll(cached.Body)
+	_ = cached.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	c.save(key, req, cached, body)
+	cached.Body = ioutil.NopCloser(bytes.NewReader(body))
+	cached.Header.Set(CacheHeader, "REVALIDATED")
+	return cached, nil
+}
+
+// ownsConditional 调用方自己设置了条件请求头时, 304 需要原样返回
+func ownsConditional(req *http.Request) bool {
+	return len(req.Header.Get("If-None-Match")) == 0 && len(req.Header.Get("If-Modified-Since")) == 0
+}
+
+// load 读取缓存的响应与保存时间, Vary 指定的请求头不一致时删除缓存并视为未命中
+func (c *HTTPCache) load(key string, req *http.Request) (*http.Response, time.Time) {
+	data, ok := c.store.Get(key)
+	if !ok {
+		return nil, time.Time{}
+	}
+	resp, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(data)), req)
+	if err != nil {
+		c.store.Delete(key)
+		return nil, time.Time{}
+	}
+	for _, name := range headerTokens(resp.Header, "Vary") {
+		if name == "*" || resp.Header.Get(cacheVariedHeader+name) != req.Header.Get(name) {
+			_ = resp.Body.Close()
+			c.store.Delete(key)
+			return nil, time.Time{}
+		}
+		resp.Header.Del(cacheVariedHeader + name)
+	}
+	storedAt, err := strconv.ParseInt(resp.Header.Get(cacheStoredAtHeader), 10, 64)
+	resp.Header.Del(cacheStoredAtHeader)
+	if err != nil {
+		_ = resp.Body.Close()
+		c.store.Delete(key)
+		return nil, time.Time{}
+	}
+	return resp, time.Unix(0, storedAt)
+}
+
+// fresh 根据 max-age 或 Expires 判断是否可以直接使用缓存
+func (c *HTTPCache) fresh(resp *http.Response, storedAt time.Time, reqControl cacheControl) bool {
+	respControl := parseCacheControl(resp.Header)
+	if reqControl.has("no-cache") || respControl.has("no-cache") || resp.Header.Get("Pragma") == "no-cache" {
+		return false
+	}
+	age := c.Now().Sub(storedAt)
+	if seconds, err := strconv.Atoi(resp.Header.Get("Age")); err == nil {
+		age += time.Duration(seconds) * time.Second
+	}
+
+	var lifetime time.Duration
+	if maxAge, ok := respControl.duration("max-age"); ok {
+		lifetime = maxAge
+	} else if expires, err := http.ParseTime(resp.Header.Get("Expires")); err == nil {
+		date, err := http.ParseTime(resp.Header.Get("Date"))
+		if err != nil {
+			date = storedAt
+		}
+		lifetime = expires.Sub(date)
+	}
+	if maxAge, ok := reqControl.duration("max-age"); ok && maxAge < lifetime {
+		lifetime = maxAge
+	}
+	return age < lifetime
+}
+
+// storeOnRead 响应体读取完成后写入缓存, 不会阻塞 Stream 与 Watch
+func (c *HTTPCache) storeOnRead(key string, req *http.Request, resp *http.Response) *http.Response {
+	if !c.cacheable(req, resp) {
+		return resp
+	}
+	resp.Body = &cachingBody{
+		ReadCloser: resp.Body,
+		limit:      c.MaxBodySize,
+		done: func(body []byte) {
+			c.save(key, req, resp, body)
+		},
+	}
+	return resp
+}
+
+func (c *HTTPCache) cacheable(req *http.Request, resp *http.Response) bool {
+	if resp.StatusCode != http.StatusOK || resp.Body == nil {
+		return false
+	}
+	control := parseCacheControl(resp.Header)
+	// 缓存可能被多个用户共享, 不保存 private 响应 (RFC 9111 5.2.2.7)
+	if control.has("no-store") || control.has("private") {
+		return false
+	}
+	// 带有凭证的响应只有服务端明确允许时才能共享
+	if len(req.Header.Get("Authorization")) > 0 && !control.has("public") && !control.has("s-maxage") && !control.has("must-revalidate") {
+		return false
+	}
+	if _, ok := control.duration("max-age"); ok {
+		return true
+	}
+	return len(resp.Header.Get("Expires")) > 0 || len(resp.Header.Get("ETag")) > 0 || len(resp.Header.Get("Last-Modified")) > 0
+}
+
+// save 同时保存 Vary 指定的请求头, 用于下次命中时比较
+func (c *HTTPCache) save(key string, req *http.Request, resp *http.Response, body []byte) {
+	stored := *resp
+	stored.Header = CloneHeader(resp.Header)
+	stored.Header.Set(cacheStoredAtHeader, strconv.FormatInt(c.Now().UnixNano(), 10))
+	for _, name := range headerTokens(resp.Header, "Vary") {
+		stored.Header.Set(cacheVariedHeader+name, req.Header.Get(name))
+	}
+	stored.Header.Del(CacheHeader)
+	stored.Body = ioutil.NopCloser(bytes.NewReader(body))
+	stored.ContentLength = int64(len(body))
+	stored.TransferEncoding = nil
+	data, err := httputil.DumpResponse(&stored, true)
+	if err != nil {
+		return
+	}
+	c.store.Set(key, data)
+}
+
+// cachingBody 读取到 EOF 时回调完整的响应体, 超过 limit 或读取出错时放弃
+type cachingBody struct {
+	io.ReadCloser
+	limit int64
+	done  func(body []byte)
+
+	buf     bytes.Buffer
+	aborted bool
+}
+
+func (b *cachingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if !b.aborted {
+		if int64(b.buf.Len()+n) > b.limit {
+			b.aborted = true
+			b.buf = bytes.Buffer{}
+		} else {
+			b.buf.Write(p[:n])
+		}
+	}
+	if err != nil {
+		if err == io.EOF && !b.aborted {
+			b.done(b.buf.Bytes())
+		}
+		b.aborted = true
+	}
+	return n, err
+}
+
+type cacheControl map[string]string
+
+func parseCacheControl(header http.Header) cacheControl {
+	control := cacheControl{}
+	for _, directive := range headerTokens(header, "Cache-Control") {
+		name, value := directive, ""
+		if i := strings.IndexByte(directive, '='); i >= 0 {
+			name, value = directive[:i], strings.Trim(directive[i+1:], `"`)
+		}
+		control[strings.ToLower(name)] = value
+	}
+	return control
+}
+
+func (c cacheControl) has(name string) bool {
+	_, ok := c[name]
+	return ok
+}
+
+func (c cacheControl) duration(name string) (time.Duration, bool) {
+	value, ok := c[name]
+	if !ok {
+		return 0, false
+	}
+	seconds, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return time.Duration(seconds) * time.Second, true
+}
+
+// headerTokens 逗号分隔的响应头, 例如 Cache-Control 与 Vary
+func headerTokens(header http.Header, name string) []string {
+	var tokens []string
+	for _, value := range header.Values(name) {
+		for _, token := range strings.Split(value, ",") {
+			if token = strings.TrimSpace(token); len(token) > 0 {
+				tokens = append(tokens, token)
+			}
+		}
+	}
+	return tokens
+}
@@ -0,0 +1,218 @@
+package rest
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHTTPCache(t *testing.T) {
+	var calls, version int32 = 0, 1
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		etag := fmt.Sprintf(`"v%d"`, atomic.LoadInt32(&version))
+		switch r.URL.Path {
+		case "/config":
+			w.Header().Set("Cache-Control", "max-age=60")
+			w.Header().Set("ETag", etag)
+			if r.Header.Get("If-None-Match") == etag {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			_, _ = fmt.Fprintf(w, `{"version":%s}`, etag[2:len(etag)-1])
+		case "/nostore":
+			w.Header().Set("Cache-Control", "no-store")
+			_, _ = w.Write([]byte(`{}`))
+		}
+	}))
+	defer server.Close()
+
+	clock := &fakeClock{now: time.Now()}
+	cache := NewHTTPCache(NewLRUCacheStore(10))
+	cache.Now = clock.Now
+	client, err := NewRESTClientFor(&Config{Host: server.URL, WrapTransport: cache.WrapTransport})
+	if err != nil {
+		t.Fatal(err)
+	}
+	get := func(path string) string {
+		body, err := client.Get().Path(path).DoRaw(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(body)
+	}
+
+	if body := get("/config"); body != `{"version":1}` {
+		t.Fatalf("unexpected body %s", body)
+	}
+	// 未过期时直接使用缓存
+	clock.Step(30 * time.Second)
+	if body := get("/config"); body != `{"version":1}` || atomic.LoadInt32(&calls) != 1 {
+		t.Fatalf("expected cache hit, body %s calls %d", body, calls)
+	}
+	// 过期后使用 ETag 重新验证, 304 返回缓存的响应体
+	clock.Step(time.Minute)
+	if body := get("/config"); body != `{"version":1}` || atomic.LoadInt32(&calls) != 2 {
+		t.Fatalf("expected revalidation, body %s calls %d", body, calls)
+	}
+	// 304 之后重新计算过期时间
+	if body := get("/config"); body != `{"version":1}` || atomic.LoadInt32(&calls) != 2 {
+		t.Fatalf("expected cache hit after revalidation, body %s calls %d", body, calls)
+	}
+	// 内容变化后返回新的响应并更新缓存
+	clock.Step(2 * time.Minute)
+	atomic.StoreInt32(&version, 2)
+	if body := get("/config"); body != `{"version":2}` {
+		t.Fatalf("unexpected body %s", body)
+	}
+	if body := get("/config"); body != `{"version":2}` || atomic.LoadInt32(&calls) != 3 {
+		t.Fatalf("expected updated cache entry, body %s calls %d", body, calls)
+	}
+	// 修改同一地址后删除缓存
+	if _, err := client.Put().Path("/config").Body([]byte(`{}`)).DoRaw(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	get("/config")
+	if atomic.LoadInt32(&calls) != 5 {
+		t.Fatalf("expected PUT to invalidate the cache, calls %d", calls)
+	}
+
+	get("/nostore")
+	get("/nostore")
+	if atomic.LoadInt32(&calls) != 7 {
+		t.Fatalf("expected no-store responses not to be cached, calls %d", calls)
+	}
+
+	stats := cache.Stats()
+	expected := CacheStats{Hits: 3, Misses: 4, Revalidations: 2, NotModified: 1}
+	if stats != expected {
+		t.Errorf("expected stats %+v, got %+v", expected, stats)
+	}
+}
+
+func TestHTTPCache_Vary(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Header().Set("Vary", "Accept")
+		_, _ = w.Write([]byte(r.Header.Get("Accept")))
+	}))
+	defer server.Close()
+
+	rt := NewHTTPCache(NewLRUCacheStore(0)).WrapTransport(http.DefaultTransport)
+	get := func(accept string) (string, http.Header) {
+		req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+		req.Header.Set("Accept", accept)
+		resp, err := rt.RoundTrip(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		body, _ := ioutil.ReadAll(resp.Body)
+		return string(body), resp.Header
+	}
+	get("application/json")
+	if body, header := get("application/xml"); body != "application/xml" {
+		t.Fatalf("expected a different Accept to miss, got %s %v", body, header)
+	}
+	// 只保存最新的变体
+	if _, header := get("application/json"); header.Get(CacheHeader) == "HIT" || atomic.LoadInt32(&calls) != 3 {
+		t.Fatalf("expected the previous variant to be replaced, calls %d", calls)
+	}
+	get("application/xml")
+	body, header := get("application/xml")
+	if body != "application/xml" || header.Get(CacheHeader) != "HIT" || atomic.LoadInt32(&calls) != 4 {
+		t.Fatalf("expected cache hit, got %s %v calls %d", body, header, calls)
+	}
+	for name := range header {
+		if name == cacheStoredAtHeader || name == cacheVariedHeader+"Accept" {
+			t.Errorf("internal header %s leaked", name)
+		}
+	}
+}
+
+func TestHTTPCache_Authorization(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.Header().Set("Cache-Control", "max-age=60")
+		switch r.URL.Path {
+		case "/public":
+			w.Header().Set("Cache-Control", "public, max-age=60")
+		case "/private":
+			w.Header().Set("Cache-Control", "private, max-age=60")
+		}
+		_, _ = w.Write([]byte(r.Header.Get("Authorization")))
+	}))
+	defer server.Close()
+
+	rt := NewHTTPCache(NewLRUCacheStore(0)).WrapTransport(http.DefaultTransport)
+	get := func(path, token string) string {
+		req, _ := http.NewRequest(http.MethodGet, server.URL+path, nil)
+		if len(token) > 0 {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := rt.RoundTrip(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		body, _ := ioutil.ReadAll(resp.Body)
+		return string(body)
+	}
+	// 带有凭证的响应不能返回给其他用户
+	get("/user", "a")
+	if body := get("/user", "b"); body != "Bearer b" || atomic.LoadInt32(&calls) != 2 {
+		t.Fatalf("expected authorized response not to be shared, got %s calls %d", body, calls)
+	}
+	// public 响应可以共享
+	get("/public", "a")
+	if body := get("/public", "b"); body != "Bearer a" || atomic.LoadInt32(&calls) != 3 {
+		t.Fatalf("expected public response to be cached, got %s calls %d", body, calls)
+	}
+	// private 响应即使没有凭证也不缓存
+	get("/private", "")
+	if body := get("/private", "b"); body != "Bearer b" || atomic.LoadInt32(&calls) != 5 {
+		t.Fatalf("expected private response not to be cached, got %s calls %d", body, calls)
+	}
+}
+
+func TestCacheStore(t *testing.T) {
+	disk, err := NewDiskCacheStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, store := range map[string]CacheStore{"lru": NewLRUCacheStore(2), "disk": disk} {
+		store.Set("a", []byte("1"))
+		store.Set("b", []byte("2"))
+		if value, ok := store.Get("a"); !ok || string(value) != "1" {
+			t.Errorf("%s: unexpected value %s", name, value)
+		}
+		store.Set("b", []byte("3"))
+		if value, _ := store.Get("b"); string(value) != "3" {
+			t.Errorf("%s: expected overwrite, got %s", name, value)
+		}
+		store.Delete("a")
+		if _, ok := store.Get("a"); ok {
+			t.Errorf("%s: expected a to be deleted", name)
+		}
+	}
+
+	lru := NewLRUCacheStore(2)
+	lru.Set("a", nil)
+	lru.Set("b", nil)
+	lru.Get("a")
+	lru.Set("c", nil)
+	if _, ok := lru.Get("b"); ok {
+		t.Error("expected least recently used entry to be evicted")
+	}
+	if _, ok := lru.Get("a"); !ok {
+		t.Error("expected recently used entry to be kept")
+	}
+}
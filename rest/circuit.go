@@ -0,0 +1,325 @@
+package rest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen 熔断器打开时请求直接失败, errors.Is(err, ErrCircuitOpen) 判断
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// CircuitOpenError 熔断器打开时返回的错误
+type CircuitOpenError struct {
+	Key string
+	// Until 之后进入半开状态, 允许探测请求
+	Until time.Time
+}
+
+func (e *CircuitOpenError) Error() string {
+	return fmt.Sprintf("circuit breaker is open for %s until %s", e.Key, e.Until.Format(time.RFC3339))
+}
+
+func (e *CircuitOpenError) Is(target error) bool {
+	return target == ErrCircuitOpen
+}
+
+// IsCircuitOpen 是否因为熔断而失败
+func IsCircuitOpen(err error) bool {
+	return errors.Is(err, ErrCircuitOpen)
+}
+
+type CircuitState int
+
+const (
+	CircuitClosed CircuitState = iota
+	CircuitOpen
+	CircuitHalfOpen
+)
+
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	}
+	return ""
+}
+
+// CircuitKeyHost 按 host 熔断
+func CircuitKeyHost(req *http.Request) string {
+	return req.URL.Host
+}
+
+// CircuitKeyRoute 按 host 与路由模板熔断, 没有路由模板的请求共享 host 下的同一个熔断器, 非 rest.Request 发起的请求使用路径
+func CircuitKeyRoute(req *http.Request) string {
+	if info, ok := RequestInfoFrom(req.Context()); ok {
+		return req.URL.Host + info.Route
+	}
+	return req.URL.Host + req.URL.Path
+}
+
+// DefaultCircuitFailure 网络错误与 5xx 响应计为失败
+// 被取消的请求 (包括对冲请求中落败的请求) 不会调用 IsFailure, 既不计为成功也不计为失败
+func DefaultCircuitFailure(resp *http.Response, err error) bool {
+	if err != nil {
+		return !errors.Is(err, context.Canceled)
+	}
+	return resp.StatusCode >= http.StatusInternalServerError
+}
+
+// CircuitBreakerConfig 熔断配置, 零值字段使用默认值
+type CircuitBreakerConfig struct {
+	// Key 熔断的粒度, 默认为 CircuitKeyHost
+	Key func(req *http.Request) string
+	// FailureRatio 窗口内失败比例达到该值时打开, 默认 0.5
+	FailureRatio float64
+	// MinRequests 窗口内请求数达到该值才会计算失败比例, 默认 20
+	MinRequests int
+	// Window 统计窗口, 默认 10s, 按 Buckets 分桶滚动
+	Window  time.Duration
+	Buckets int
+	// Cooldown 打开后等待多久进入半开状态, 默认 30s
+	Cooldown time.Duration
+	// HalfOpenRequests 半开状态下允许的探测请求数, 全部成功后关闭, 默认 1
+	HalfOpenRequests int
+	// IsFailure 默认为 DefaultCircuitFailure
+	IsFailure func(resp *http.Response, err error) bool
+	// OnStateChange 状态变化时调用, 不持有锁, 可以用于告警
+	OnStateChange func(key string, from, to CircuitState)
+	// Now 默认为 time.Now
+	Now func() time.Time
+}
+
+func (c *CircuitBreakerConfig) complete() {
+	if c.Key == nil {
+		c.Key = CircuitKeyHost
+	}
+	if c.FailureRatio <= 0 {
+		c.FailureRatio = 0.5
+	}
+	if c.MinRequests <= 0 {
+		c.MinRequests = 20
+	}
+	if c.Window <= 0 {
+		c.Window = 10 * time.Second
+	}
+	if c.Buckets <= 0 {
+		c.Buckets = 10
+	}
+	if c.Cooldown <= 0 {
+		c.Cooldown = 30 * time.Second
+	}
+	if c.HalfOpenRequests <= 0 {
+		c.HalfOpenRequests = 1
+	}
+	if c.IsFailure == nil {
+		c.IsFailure = DefaultCircuitFailure
+	}
+	if c.Now == nil {
+		c.Now = time.Now
+	}
+}
+
+// NewCircuitBreaker 返回按 Key 熔断的 WrapperFunc, 同一个 WrapperFunc 包装的 Transport 共享状态
+func NewCircuitBreaker(config CircuitBreakerConfig) WrapperFunc {
+	config.complete()
+	breakers := &circuitBreakers{
+		config:   config,
+		breakers: make(map[string]*circuitBreaker),
+	}
+	return func(rt http.RoundTripper) http.RoundTripper {
+		return &circuitRoundTripper{breakers: breakers, rt: rt}
+	}
+}
+
+type circuitBreakers struct {
+	config CircuitBreakerConfig
+
+	mu       sync.Mutex
+	breakers map[string]*circuitBreaker
+}
+
+func (b *circuitBreakers) get(key string) *circuitBreaker {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	breaker, ok := b.breakers[key]
+	if !ok {
+		breaker = &circuitBreaker{
+			key:     key,
+			config:  &b.config,
+			buckets: make([]circuitBucket, b.config.Buckets),
+		}
+		b.breakers[key] = breaker
+	}
+	return breaker
+}
+
+type circuitRoundTripper struct {
+	breakers *circuitBreakers
+	rt       http.RoundTripper
+}
+
+func (rt *circuitRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	breaker := rt.breakers.get(rt.breakers.config.Key(req))
+	generation, err := breaker.allow()
+	if err != nil {
+		if req.Body != nil {
+			_ = req.Body.Close()
+		}
+		return nil, err
+	}
+	resp, err := rt.rt.RoundTrip(req)
+	if err != nil && (errors.Is(err, context.Canceled) || errors.Is(req.Context().Err(), context.Canceled)) {
+		breaker.release(generation)
+		return resp, err
+	}
+	breaker.record(generation, rt.breakers.config.IsFailure(resp, err))
+	return resp, err
+}
+
+type circuitBucket struct {
+	start     time.Time
+	successes int
+	failures  int
+}
+
+// circuitBreaker 单个 key 的熔断状态
+type circuitBreaker struct {
+	key    string
+	config *CircuitBreakerConfig
+
+	mu    sync.Mutex
+	state CircuitState
+	// generation 每次状态变化加一, 忽略旧状态下发出的请求结果
+	generation uint64
+	buckets    []circuitBucket
+	openedAt   time.Time
+	// probes 半开状态下已发出与已成功的探测请求
+	probes    int
+	successes int
+}
+
+func (b *circuitBreaker) allow() (uint64, error) {
+	b.mu.Lock()
+	now := b.config.Now()
+	var changed func()
+	switch b.state {
+	case CircuitOpen:
+		until := b.openedAt.Add(b.config.Cooldown)
+		if now.Before(until) {
+			b.mu.Unlock()
+			return 0, &CircuitOpenError{Key: b.key, Until: until}
+		}
+		changed = b.setState(CircuitHalfOpen)
+		fallthrough
+	case CircuitHalfOpen:
+		if b.probes >= b.config.HalfOpenRequests {
+			b.mu.Unlock()
+			return 0, &CircuitOpenError{Key: b.key, Until: now}
+		}
+		b.probes++
+	}
+	generation := b.generation
+	b.mu.Unlock()
+	if changed != nil {
+		changed()
+	}
+	return generation, nil
+}
+
+func (b *circuitBreaker) record(generation uint64, failure bool) {
+	b.mu.Lock()
+	if generation != b.generation {
+		b.mu.Unlock()
+		return
+	}
+	now := b.config.Now()
+	var changed func()
+	switch b.state {
+	case CircuitClosed:
+		bucket := b.bucket(now)
+		if failure {
+			bucket.failures++
+		} else {
+			bucket.successes++
+		}
+		if successes, failures := b.counts(now); successes+failures >= b.config.MinRequests &&
+			float64(failures)/float64(successes+failures) >= b.config.FailureRatio {
+			b.openedAt = now
+			changed = b.setState(CircuitOpen)
+		}
+	case CircuitHalfOpen:
+		if failure {
+			b.openedAt = now
+			changed = b.setState(CircuitOpen)
+		} else if b.successes++; b.successes >= b.config.HalfOpenRequests {
+			changed = b.setState(CircuitClosed)
+		}
+	}
+	b.mu.Unlock()
+	if changed != nil {
+		changed()
+	}
+}
+
+// release 请求被取消时不记录结果, 归还半开状态下的探测名额
+func (b *circuitBreaker) release(generation uint64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if generation == b.generation && b.state == CircuitHalfOpen && b.probes > 0 {
+		b.probes--
+	}
+}
+
+// setState 需要持有锁, 返回的回调需要在释放锁之后调用
+func (b *circuitBreaker) setState(state CircuitState) func() {
+	from := b.state
+	b.state = state
+	b.generation++
+	b.probes, b.successes = 0, 0
+	if state == CircuitClosed {
+		for i := range b.buckets {
+			b.buckets[i] = circuitBucket{}
+		}
+	}
+	if b.config.OnStateChange == nil {
+		return nil
+	}
+	key, callback := b.key, b.config.OnStateChange
+	return func() {
+		callback(key, from, state)
+	}
+}
+
+func (b *circuitBreaker) bucketSize() time.Duration {
+	return b.config.Window / time.Duration(len(b.buckets))
+}
+
+// bucket 返回 now 所在的桶, 过期的桶会被重置
+func (b *circuitBreaker) bucket(now time.Time) *circuitBucket {
+	size := b.bucketSize()
+	start := now.Truncate(size)
+	bucket := &b.buckets[int(start.UnixNano()/int64(size))%len(b.buckets)]
+	if !bucket.start.Equal(start) {
+		*bucket = circuitBucket{start: start}
+	}
+	return bucket
+}
+
+func (b *circuitBreaker) counts(now time.Time) (successes, failures int) {
+	oldest := now.Truncate(b.bucketSize()).Add(-b.config.Window)
+	for _, bucket := range b.buckets {
+		if bucket.start.After(oldest) {
+			successes += bucket.successes
+			failures += bucket.failures
+		}
+	}
+	return successes, failures
+}
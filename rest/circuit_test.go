@@ -0,0 +1,178 @@
+package rest
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type stateChange struct {
+	key      string
+	from, to CircuitState
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	var healthy int32
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		if atomic.LoadInt32(&healthy) == 0 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	clock := &fakeClock{now: time.Now()}
+	var changes []stateChange
+	client, err := NewRESTClientFor(&Config{
+		Host:       server.URL,
+		MaxRetries: 3,
+		Backoff:    Backoff{Duration: time.Millisecond},
+		WrapTransport: NewCircuitBreaker(CircuitBreakerConfig{
+			MinRequests: 4,
+			Cooldown:    time.Minute,
+			Now:         clock.Now,
+			OnStateChange: func(key string, from, to CircuitState) {
+				changes = append(changes, stateChange{key, from, to})
+			},
+		}),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	host := server.Listener.Addr().String()
+
+	// 第一次请求重试 3 次后打开熔断
+	err = client.Get().Path("/").Do(context.Background()).Error()
+	if !IsServerError(err) {
+		t.Fatalf("expected server error, got %v", err)
+	}
+	if len(changes) != 1 || changes[0] != (stateChange{host, CircuitClosed, CircuitOpen}) {
+		t.Fatalf("unexpected state changes %v", changes)
+	}
+
+	// 打开状态下直接失败, 不重试
+	err = client.Get().Path("/").Do(context.Background()).Error()
+	var openErr *CircuitOpenError
+	if !IsCircuitOpen(err) || !errors.As(err, &openErr) || openErr.Key != host {
+		t.Fatalf("expected circuit open error, got %v", err)
+	}
+	if n := atomic.LoadInt32(&calls); n != 4 {
+		t.Fatalf("expected open circuit to fail fast, server saw %d calls", n)
+	}
+
+	// 半开状态的探测失败后重新打开
+	clock.Step(time.Minute)
+	_ = client.Get().Path("/").MaxRetries(0).Do(context.Background()).Error()
+	if len(changes) != 3 || changes[1].to != CircuitHalfOpen || changes[2].to != CircuitOpen {
+		t.Fatalf("unexpected state changes %v", changes)
+	}
+
+	// 探测成功后关闭
+	clock.Step(time.Minute)
+	atomic.StoreInt32(&healthy, 1)
+	if err := client.Get().Path("/").Do(context.Background()).Error(); err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 5 || changes[3].to != CircuitHalfOpen || changes[4] != (stateChange{host, CircuitHalfOpen, CircuitClosed}) {
+		t.Fatalf("unexpected state changes %v", changes)
+	}
+}
+
+func TestCircuitBreaker_Window(t *testing.T) {
+	clock := &fakeClock{now: time.Now()}
+	breakers := &circuitBreakers{config: CircuitBreakerConfig{MinRequests: 4, Window: 10 * time.Second, Now: clock.Now}, breakers: map[string]*circuitBreaker{}}
+	breakers.config.complete()
+	breaker := breakers.get("host")
+
+	record := func(failure bool) {
+		generation, err := breaker.allow()
+		if err != nil {
+			t.Fatal(err)
+		}
+		breaker.record(generation, failure)
+	}
+	// 过期的失败不计入窗口
+	record(true)
+	record(true)
+	record(true)
+	clock.Step(11 * time.Second)
+	record(true)
+	record(false)
+	record(false)
+	if breaker.state != CircuitClosed {
+		t.Fatal("expected failures outside of the window to be ignored")
+	}
+	record(true)
+	if breaker.state != CircuitOpen {
+		t.Fatal("expected circuit to open at 50% failures")
+	}
+}
+
+func TestCircuitBreaker_KeyRoute(t *testing.T) {
+	var keys []string
+	wrapper := NewCircuitBreaker(CircuitBreakerConfig{
+		Key: func(req *http.Request) string {
+			key := CircuitKeyRoute(req)
+			keys = append(keys, key)
+			return key
+		},
+	})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	client, err := NewRESTClientFor(&Config{Host: server.URL, WrapTransport: wrapper})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Get().Path("/users/{id}").PathParam("id", "1").Do(context.Background()).Error(); err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 1 || keys[0] != server.Listener.Addr().String()+"/users/{id}" {
+		t.Errorf("unexpected keys %v", keys)
+	}
+}
+
+func TestCircuitBreaker_CanceledProbe(t *testing.T) {
+	var healthy int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.LoadInt32(&healthy) == 0 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	clock := &fakeClock{now: time.Now()}
+	wrapper := NewCircuitBreaker(CircuitBreakerConfig{MinRequests: 1, Cooldown: time.Minute, Now: clock.Now})
+	client, err := NewRESTClientFor(&Config{Host: server.URL, WrapTransport: wrapper})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = client.Get().Path("/").Do(context.Background()).Error()
+	clock.Step(time.Minute)
+	atomic.StoreInt32(&healthy, 1)
+
+	// 取消的探测请求不能关闭熔断, 也不能占用探测名额
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := client.Get().Path("/").Do(ctx).Error(); IsCircuitOpen(err) || err == nil {
+		t.Fatalf("expected canceled probe, got %v", err)
+	}
+	breaker := wrapper(nil).(*circuitRoundTripper).breakers.get(server.Listener.Addr().String())
+	if breaker.state != CircuitHalfOpen || breaker.probes != 0 {
+		t.Fatalf("expected canceled probe to be ignored, got %s with %d probes", breaker.state, breaker.probes)
+	}
+	if err := client.Get().Path("/").Do(context.Background()).Error(); err != nil {
+		t.Fatal(err)
+	}
+	if breaker.state != CircuitClosed {
+		t.Fatalf("expected successful probe to close the circuit, got %s", breaker.state)
+	}
+}
@@ -2,12 +2,17 @@ package rest
 
 import (
 	"encoding/json"
+	"encoding/xml"
 	"errors"
 	"fmt"
 	"net/url"
 	"reflect"
 	"strconv"
 	"strings"
+
+	"github.com/vmihailenco/msgpack/v5"
+	"google.golang.org/protobuf/proto"
+	"gopkg.in/yaml.v2"
 )
 
 type ParameterCodec interface {
@@ -175,3 +180,113 @@ func (j jsonMarshaler) Marshal(v interface{}) ([]byte, error) {
 func (j jsonMarshaler) Unmarshal(bytes []byte, v interface{}) error {
 	return json.Unmarshal(bytes, v)
 }
+
+type xmlMarshaler struct{}
+
+var _ Marshaler = &xmlMarshaler{}
+
+func NewXmlMarshaler() Marshaler {
+	return &xmlMarshaler{}
+}
+
+func (x xmlMarshaler) Marshal(v interface{}) ([]byte, error) {
+	return xml.Marshal(v)
+}
+
+func (x xmlMarshaler) Unmarshal(bytes []byte, v interface{}) error {
+	return xml.Unmarshal(bytes, v)
+}
+
+type yamlMarshaler struct{}
+
+var _ Marshaler = &yamlMarshaler{}
+
+func NewYamlMarshaler() Marshaler {
+	return &yamlMarshaler{}
+}
+
+func (y yamlMarshaler) Marshal(v interface{}) ([]byte, error) {
+	return yaml.Marshal(v)
+}
+
+func (y yamlMarshaler) Unmarshal(bytes []byte, v interface{}) error {
+	return yaml.Unmarshal(bytes, v)
+}
+
+type msgpackMarshaler struct{}
+
+var _ Marshaler = &msgpackMarshaler{}
+
+func NewMsgpackMarshaler() Marshaler {
+	return &msgpackMarshaler{}
+}
+
+func (m msgpackMarshaler) Marshal(v interface{}) ([]byte, error) {
+	return msgpack.Marshal(v)
+}
+
+func (m msgpackMarshaler) Unmarshal(bytes []byte, v interface{}) error {
+	return msgpack.Unmarshal(bytes, v)
+}
+
+// formMarshaler application/x-www-form-urlencoded, 结构体使用 param tag
+type formMarshaler struct {
+	codec ParameterCodec
+}
+
+var _ Marshaler = &formMarshaler{}
+
+func NewFormMarshaler() Marshaler {
+	return &formMarshaler{codec: NewParameterCodec()}
+}
+
+func (f formMarshaler) Marshal(v interface{}) ([]byte, error) {
+	switch t := v.(type) {
+	case url.Values:
+		return []byte(t.Encode()), nil
+	case *url.Values:
+		return []byte(t.Encode()), nil
+	}
+	values, err := f.codec.EncodeParameters(v)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(values.Encode()), nil
+}
+
+func (f formMarshaler) Unmarshal(bytes []byte, v interface{}) error {
+	values, err := url.ParseQuery(string(bytes))
+	if err != nil {
+		return err
+	}
+	if t, ok := v.(*url.Values); ok {
+		*t = values
+		return nil
+	}
+	return f.codec.DecodeParameters(values, v)
+}
+
+// protobufMarshaler application/x-protobuf, 仅支持 proto.Message
+type protobufMarshaler struct{}
+
+var _ Marshaler = &protobufMarshaler{}
+
+func NewProtobufMarshaler() Marshaler {
+	return &protobufMarshaler{}
+}
+
+func (p protobufMarshaler) Marshal(v interface{}) ([]byte, error) {
+	m, ok := v.(proto.Message)
+	if !ok {
+		return nil, fmt.Errorf("protobuf: %T does not implement proto.Message", v)
+	}
+	return proto.Marshal(m)
+}
+
+func (p protobufMarshaler) Unmarshal(bytes []byte, v interface{}) error {
+	m, ok := v.(proto.Message)
+	if !ok {
+		return fmt.Errorf("protobuf: %T does not implement proto.Message", v)
+	}
+	return proto.Unmarshal(bytes, m)
+}
@@ -1,10 +1,18 @@
 package rest
 
 import (
+	"context"
 	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
 	"net/url"
 	"reflect"
 	"testing"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
 )
 
 func TestUrlValues(t *testing.T) {
@@ -79,3 +87,55 @@ func TestParameterCodec_DecodeParameters(t *testing.T) {
 		t.Log(reflect.TypeOf(user.Age))
 	}
 }
+
+func TestProtobufMarshaler(t *testing.T) {
+	m := NewProtobufMarshaler()
+	if _, err := m.Marshal(map[string]string{"a": "b"}); err == nil {
+		t.Fatal("expected error for non proto.Message value")
+	}
+	var out map[string]string
+	if err := m.Unmarshal(nil, &out); err == nil {
+		t.Fatal("expected error for non proto.Message value")
+	}
+}
+
+func TestProtobufMarshaler_Request(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Type") != "application/x-protobuf" {
+			w.WriteHeader(http.StatusUnsupportedMediaType)
+			return
+		}
+		body, _ := ioutil.ReadAll(r.Body)
+		in := &wrapperspb.StringValue{}
+		if err := proto.Unmarshal(body, in); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		out, _ := structpb.NewStruct(map[string]interface{}{"name": in.GetValue(), "ok": true})
+		data, _ := proto.Marshal(out)
+		w.Header().Set("Content-Type", "application/x-protobuf")
+		_, _ = w.Write(data)
+	}))
+	defer server.Close()
+
+	client, err := NewRESTClientFor(&Config{
+		Host:          server.URL,
+		ContentConfig: ContentConfig{ContentType: "application/x-protobuf"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := &structpb.Struct{}
+	err = client.Post().Path("/users").Body(wrapperspb.String("gopher")).Do(context.Background()).Into(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.Fields["name"].GetStringValue() != "gopher" || !out.Fields["ok"].GetBoolValue() {
+		t.Errorf("unexpected response %v", out)
+	}
+
+	err = client.Post().Path("/users").Body(map[string]string{"name": "gopher"}).Do(context.Background()).Error()
+	if err == nil {
+		t.Fatal("expected error for non proto.Message body")
+	}
+}
@@ -0,0 +1,254 @@
+package rest
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/klauspost/compress/flate"
+	"github.com/klauspost/compress/gzip"
+	"github.com/klauspost/compress/zlib"
+	"github.com/klauspost/compress/zstd"
+)
+
+const (
+	EncodingGzip    = "gzip"
+	EncodingDeflate = "deflate"
+	EncodingZstd    = "zstd"
+)
+
+// DefaultCompressionMinSize 请求体超过该大小才会压缩
+const DefaultCompressionMinSize = 1024
+
+// CompressionConfig 请求体压缩与响应解压配置
+type CompressionConfig struct {
+	// Encoding 请求体压缩算法, gzip 或 zstd, 为空时只解压响应
+	Encoding string
+	// MinSize 请求体大小超过 MinSize 时压缩, 默认为 DefaultCompressionMinSize
+	// 长度未知的请求体 (例如 multipart) 不压缩
+	MinSize int64
+	// Level 压缩级别, 为 0 时使用算法的默认级别
+	Level int
+}
+
+// NewCompressionWrapper 返回压缩请求体并解压 gzip, deflate, zstd 响应的 WrapperFunc
+// 设置 Accept-Encoding 后 http.Transport 不再自动解压 gzip, 由该 WrapperFunc 负责
+func NewCompressionWrapper(config CompressionConfig) (WrapperFunc, error) {
+	switch config.Encoding {
+	case "", EncodingGzip, EncodingZstd:
+	default:
+		return nil, fmt.Errorf("compression: unsupported request encoding %q", config.Encoding)
+	}
+	if config.MinSize <= 0 {
+		config.MinSize = DefaultCompressionMinSize
+	}
+	rt := &compressionRoundTripper{config: config}
+	if config.Encoding == EncodingGzip && config.Level != 0 {
+		if _, err := gzip.NewWriterLevel(ioutil.Discard, config.Level); err != nil {
+			return nil, fmt.Errorf("compression: %w", err)
+		}
+	}
+	if config.Encoding == EncodingZstd {
+		encoder, err := sharedZstdEncoder(config.Level)
+		if err != nil {
+			return nil, fmt.Errorf("compression: %w", err)
+		}
+		rt.zstd = encoder
+	}
+	return func(base http.RoundTripper) http.RoundTripper {
+		return &compressionRoundTripper{config: rt.config, zstd: rt.zstd, rt: base}
+	}, nil
+}
+
+var (
+	zstdEncodersMu sync.Mutex
+	zstdEncoders   = make(map[zstd.EncoderLevel]*zstd.Encoder)
+)
+
+// sharedZstdEncoder 相同压缩级别共享一个 Encoder
+// 只调用 EncodeAll 不会启动后台 goroutine, 不需要关闭
+func sharedZstdEncoder(level int) (*zstd.Encoder, error) {
+	encoderLevel := zstd.SpeedDefault
+	if level != 0 {
+		encoderLevel = zstd.EncoderLevelFromZstd(level)
+	}
+	zstdEncodersMu.Lock()
+	defer zstdEncodersMu.Unlock()
+	if encoder, ok := zstdEncoders[encoderLevel]; ok {
+		return encoder, nil
+	}
+	encoder, err := zstd.NewWriter(nil, zstd.WithEncoderLevel(encoderLevel))
+	if err != nil {
+		return nil, err
+	}
+	zstdEncoders[encoderLevel] = encoder
+	return encoder, nil
+}
+
+type compressionRoundTripper struct {
+	config CompressionConfig
+	// zstd 只使用 EncodeAll, 可以并发调用
+	zstd *zstd.Encoder
+	rt   http.RoundTripper
+}
+
+func (rt *compressionRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = CloneRequest(req)
+	if len(req.Header.Get("Accept-Encoding")) == 0 {
+		req.Header.Set("Accept-Encoding", "gzip, deflate, zstd")
+	}
+	if err := rt.compress(req); err != nil {
+		return nil, err
+	}
+	resp, err := rt.rt.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	decompress(resp)
+	return resp, nil
+}
+
+// compress 压缩长度已知且超过 MinSize 的请求体, 已经设置 Content-Encoding 时不处理
+func (rt *compressionRoundTripper) compress(req *http.Request) error {
+	if len(rt.config.Encoding) == 0 || req.ContentLength < rt.config.MinSize {
+		return nil
+	}
+	if len(req.Header.Get("Content-Encoding")) != 0 {
+		return nil
+	}
+	// 请求体大小按压缩后的字节数统计
+	counting, _ := req.Body.(*countingReadCloser)
+	if counting != nil {
+		req.Body = counting.ReadCloser
+	}
+	body, err := readRequestBody(req)
+	if err != nil {
+		return err
+	}
+
+	var data []byte
+	switch rt.config.Encoding {
+	case EncodingGzip:
+		data, err = gzipEncode(body, rt.config.Level)
+	case EncodingZstd:
+		data = rt.zstd.EncodeAll(body, nil)
+	}
+	if err != nil {
+		return err
+	}
+	req.Body = ioutil.NopCloser(bytes.NewReader(data))
+	if counting != nil {
+		req.Body = &countingReadCloser{ReadCloser: req.Body, report: counting.report}
+	}
+	req.GetBody = func() (io.ReadCloser, error) {
+		return ioutil.NopCloser(bytes.NewReader(data)), nil
+	}
+	req.ContentLength = int64(len(data))
+	req.Header.Set("Content-Encoding", rt.config.Encoding)
+	return nil
+}
+
+func gzipEncode(data []byte, level int) ([]byte, error) {
+	if level == 0 {
+		level = gzip.DefaultCompression
+	}
+	var buf bytes.Buffer
+	writer, err := gzip.NewWriterLevel(&buf, level)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := writer.Write(data); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// decompress 替换为解压后的响应体, 并移除 Content-Encoding 与 Content-Length
+func decompress(resp *http.Response) {
+	encoding := strings.ToLower(strings.TrimSpace(resp.Header.Get("Content-Encoding")))
+	switch encoding {
+	case EncodingGzip, "x-gzip", EncodingDeflate, EncodingZstd:
+	default:
+		return
+	}
+	if resp.Body == nil || resp.Body == http.NoBody {
+		return
+	}
+	resp.Body = &decompressReader{encoding: encoding, body: resp.Body}
+	resp.Header.Del("Content-Encoding")
+	resp.Header.Del("Content-Length")
+	resp.ContentLength = -1
+	resp.Uncompressed = true
+}
+
+// decompressReader 在第一次 Read 时创建解压器, 空响应体 (例如 HEAD) 不会返回错误
+type decompressReader struct {
+	encoding string
+	body     io.ReadCloser
+
+	once   sync.Once
+	reader io.Reader
+	closer func()
+	err    error
+}
+
+func (d *decompressReader) Read(p []byte) (int, error) {
+	d.once.Do(d.init)
+	if d.err != nil {
+		return 0, d.err
+	}
+	return d.reader.Read(p)
+}
+
+func (d *decompressReader) init() {
+	switch d.encoding {
+	case EncodingGzip, "x-gzip":
+		reader, err := gzip.NewReader(d.body)
+		d.reader, d.err = reader, err
+	case EncodingDeflate:
+		d.reader, d.err = newDeflateReader(d.body)
+	case EncodingZstd:
+		decoder, err := zstd.NewReader(d.body, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			d.err = err
+			return
+		}
+		d.reader, d.closer = decoder, decoder.Close
+	}
+}
+
+// Close 先关闭响应体, 使阻塞在 init 中的 Read 返回
+func (d *decompressReader) Close() error {
+	err := d.body.Close()
+	d.once.Do(func() {
+		d.err = io.ErrClosedPipe
+	})
+	if d.closer != nil {
+		d.closer()
+	}
+	return err
+}
+
+// newDeflateReader HTTP deflate 应为 zlib 格式, 部分服务端直接返回 raw deflate, 根据 zlib 头判断
+func newDeflateReader(body io.Reader) (io.Reader, error) {
+	reader := bufio.NewReader(body)
+	header, err := reader.Peek(2)
+	if err != nil {
+		if err == io.EOF {
+			return reader, nil
+		}
+		return nil, err
+	}
+	if header[0]&0x0f == 8 && (uint16(header[0])<<8|uint16(header[1]))%31 == 0 {
+		return zlib.NewReader(reader)
+	}
+	return flate.NewReader(reader), nil
+}
@@ -0,0 +1,221 @@
+package rest
+
+import (
+	"bytes"
+	"compress/zlib"
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/klauspost/compress/flate"
+	"github.com/klauspost/compress/gzip"
+	"github.com/klauspost/compress/zstd"
+)
+
+func newCompressionTestServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var reader io.Reader = r.Body
+		switch r.Header.Get("Content-Encoding") {
+		case "gzip":
+			gz, err := gzip.NewReader(r.Body)
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			reader = gz
+		case "zstd":
+			decoder, err := zstd.NewReader(r.Body)
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			defer decoder.Close()
+			reader = decoder
+		}
+		body, err := ioutil.ReadAll(reader)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("X-Request-Encoding", r.Header.Get("Content-Encoding"))
+		w.Header().Set("X-Accept-Encoding", r.Header.Get("Accept-Encoding"))
+		if len(body) == 0 {
+			body = []byte(`{"name":"response"}`)
+		}
+
+		var buf bytes.Buffer
+		encoding := r.URL.Query().Get("encoding")
+		switch encoding {
+		case "gzip":
+			writer := gzip.NewWriter(&buf)
+			_, _ = writer.Write(body)
+			_ = writer.Close()
+		case "deflate":
+			writer := zlib.NewWriter(&buf)
+			_, _ = writer.Write(body)
+			_ = writer.Close()
+		case "raw-deflate":
+			encoding = "deflate"
+			writer, _ := flate.NewWriter(&buf, flate.DefaultCompression)
+			_, _ = writer.Write(body)
+			_ = writer.Close()
+		case "zstd":
+			encoder, _ := zstd.NewWriter(nil)
+			buf.Write(encoder.EncodeAll(body, nil))
+			_ = encoder.Close()
+		default:
+			buf.Write(body)
+		}
+		if len(encoding) > 0 {
+			w.Header().Set("Content-Encoding", encoding)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method == http.MethodHead {
+			return
+		}
+		_, _ = w.Write(buf.Bytes())
+	}))
+}
+
+func TestCompression_Response(t *testing.T) {
+	server := newCompressionTestServer()
+	defer server.Close()
+
+	wrapper, err := NewCompressionWrapper(CompressionConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	client, err := NewRESTClientFor(&Config{Host: server.URL, WrapTransport: wrapper})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, encoding := range []string{"", "gzip", "deflate", "raw-deflate", "zstd"} {
+		var out struct {
+			Name string `json:"name"`
+		}
+		err := client.Get().Path("/").Param("encoding", encoding).Do(context.Background()).Into(&out)
+		if err != nil {
+			t.Fatalf("%s: %v", encoding, err)
+		}
+		if out.Name != "response" {
+			t.Errorf("%s: unexpected response %+v", encoding, out)
+		}
+	}
+
+	result := client.Verb(http.MethodHead).Path("/").Param("encoding", "gzip").Do(context.Background())
+	if result.Error() != nil {
+		t.Fatalf("head: %v", result.Error())
+	}
+}
+
+func TestCompression_Request(t *testing.T) {
+	server := newCompressionTestServer()
+	defer server.Close()
+
+	payload := `{"name":"` + strings.Repeat("x", 2048) + `"}`
+	for _, encoding := range []string{EncodingGzip, EncodingZstd} {
+		wrapper, err := NewCompressionWrapper(CompressionConfig{Encoding: encoding})
+		if err != nil {
+			t.Fatal(err)
+		}
+		client, err := NewRESTClientFor(&Config{Host: server.URL, WrapTransport: wrapper})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		body, err := client.Post().Path("/").Body([]byte(payload)).Do(context.Background()).Raw()
+		if err != nil {
+			t.Fatalf("%s: %v", encoding, err)
+		}
+		if string(body) != payload {
+			t.Errorf("%s: unexpected echo %d bytes", encoding, len(body))
+		}
+
+		resp, err := wrapper(http.DefaultTransport).RoundTrip(newTestRequest(t, server.URL, payload))
+		if err != nil {
+			t.Fatal(err)
+		}
+		readAndCloseResponseBody(resp)
+		if got := resp.Header.Get("X-Request-Encoding"); got != encoding {
+			t.Errorf("expected request encoding %q, got %q", encoding, got)
+		}
+		if got := resp.Header.Get("X-Accept-Encoding"); got != "gzip, deflate, zstd" {
+			t.Errorf("unexpected Accept-Encoding %q", got)
+		}
+
+		resp, err = wrapper(http.DefaultTransport).RoundTrip(newTestRequest(t, server.URL, "small"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		readAndCloseResponseBody(resp)
+		if got := resp.Header.Get("X-Request-Encoding"); len(got) != 0 {
+			t.Errorf("expected small body to be sent uncompressed, got %q", got)
+		}
+	}
+
+	if _, err := NewCompressionWrapper(CompressionConfig{Encoding: "br"}); err == nil {
+		t.Fatal("expected error for unsupported encoding")
+	}
+}
+
+type sizeMetrics struct {
+	NoopMetrics
+	mu    sync.Mutex
+	sizes []int64
+}
+
+func (m *sizeMetrics) RequestSize(ctx context.Context, info RequestInfo, size int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sizes = append(m.sizes, size)
+}
+
+func TestCompression_RequestSize(t *testing.T) {
+	server := newCompressionTestServer()
+	defer server.Close()
+
+	wrapper, err := NewCompressionWrapper(CompressionConfig{Encoding: EncodingZstd})
+	if err != nil {
+		t.Fatal(err)
+	}
+	metrics := &sizeMetrics{}
+	client, err := NewRESTClientFor(&Config{Host: server.URL, WrapTransport: wrapper, Metrics: metrics})
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload := strings.Repeat("x", 4096)
+	if err := client.Post().Path("/").Body([]byte(payload)).Do(context.Background()).Error(); err != nil {
+		t.Fatal(err)
+	}
+	metrics.mu.Lock()
+	defer metrics.mu.Unlock()
+	// 上报压缩后实际发送的字节数
+	if len(metrics.sizes) != 1 || metrics.sizes[0] <= 0 || metrics.sizes[0] >= int64(len(payload)) {
+		t.Fatalf("expected compressed request size to be reported, got %v", metrics.sizes)
+	}
+}
+
+func TestSharedZstdEncoder(t *testing.T) {
+	a, err := sharedZstdEncoder(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := sharedZstdEncoder(0)
+	c, _ := sharedZstdEncoder(19)
+	if a != b || a == c {
+		t.Fatal("expected encoders to be shared per level")
+	}
+}
+
+func newTestRequest(t *testing.T, url, body string) *http.Request {
+	req, err := http.NewRequest(http.MethodPost, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return req
+}
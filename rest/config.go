@@ -6,16 +6,22 @@ import (
 	"net/http"
 	"net/url"
 	"time"
+
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type Config struct {
 	Host string
+	// Path 所有请求的基础路径, 例如 /api/v2, 拼接在 Host 的路径之后
 	Path string
 
 	Username string
 	Password string
 
 	BearerToken string
+	// BearerTokenFile 定期重新读取的 token 文件, 优先于 BearerToken
+	BearerTokenFile string
 
 	UserAgent string
 
@@ -24,38 +30,74 @@ type Config struct {
 	Dial          func(ctx context.Context, network, address string) (net.Conn, error)
 	Proxy         func(*http.Request) (*url.URL, error)
 
+	DialTimeout     time.Duration
+	IdleConnTimeout time.Duration
+
+	TLSClientConfig TLSClientConfig
+
 	QPS         float32
 	Burst       int
 	RateLimiter RateLimiter
 	Timeout     time.Duration
 
+	// 重试, MaxRetries 为 0 表示不重试, Backoff 与 RetryPolicy 为空时使用默认值
+	MaxRetries  int
+	Backoff     Backoff
+	RetryPolicy RetryPolicy
+	// NewRetry 为每个请求创建 WithRetry, 由它负责重试前的等待, 为空时按 Backoff 与 Retry-After 等待
+	NewRetry func() WithRetry
+
 	AuthConfig   AuthConfig
 	AuthProvider AuthProvider
 	ContentConfig
-	// TODO:// Metric and Tracing
+	// Debug 调试日志, 排查对接问题时开启
+	Debug DebugConfig
+	// Metrics 请求指标, 为空时不记录
+	Metrics Metrics
+	// TracerProvider 为空时使用 otel.GetTracerProvider()
+	TracerProvider trace.TracerProvider
+	// Propagator 写入请求头的链路信息, 默认为 W3C traceparent/tracestate
+	Propagator propagation.TextMapPropagator
 }
 
 type ContentConfig struct {
 	AcceptContentTypes string
 	ContentType        string
-	Codec              Marshaler
-	Timeout            time.Duration
+	// Codec 用于 ContentType 以及 Codecs 中找不到对应 Marshaler 的 Content-Type
+	Codec Marshaler
+	// Codecs 根据 Content-Type 选择 Marshaler, 为空时使用 DefaultCodecRegistry
+	Codecs  *CodecRegistry
+	Timeout time.Duration
+	// ErrorDecoder 解码非 2xx 响应, 为空时使用 DefaultErrorDecoder
+	ErrorDecoder ErrorDecoder
 }
 
 func (c *Config) TransportConfig() (*TransportConfig, error) {
 	conf := &TransportConfig{
-		Username:      c.Username,
-		Password:      c.Password,
-		UserAgent:     c.UserAgent,
-		Transport:     c.Transport,
-		WrapTransport: c.WrapTransport,
-		Dial:          c.Dial,
-		Proxy:         c.Proxy,
-	}
-	// 自定义认证
-	if c.AuthProvider != nil {
+		Username:        c.Username,
+		Password:        c.Password,
+		BearerToken:     c.BearerToken,
+		BearerTokenFile: c.BearerTokenFile,
+		UserAgent:       c.UserAgent,
+		Transport:       c.Transport,
+		WrapTransport:   c.WrapTransport,
+		Dial:            c.Dial,
+		Proxy:           c.Proxy,
 
-		conf.Wrap(c.AuthProvider.WrapTransport)
+		DialTimeout:     c.DialTimeout,
+		IdleConnTimeout: c.IdleConnTimeout,
+		TLS:             c.TLSClientConfig,
+		Debug:           c.Debug,
+	}
+	// 自定义认证, 未指定 AuthProvider 时根据 AuthConfig.Name 从注册表中构造, 在构造客户端时登录
+	conf.AuthProvider = c.AuthProvider
+	if conf.AuthProvider == nil && len(c.AuthConfig.Name) > 0 {
+		provider, err := GetAuthProvider(&c.AuthConfig)
+		if err != nil {
+			return nil, err
+		}
+		conf.AuthProvider = provider
+		conf.loginAuthProvider = true
 	}
 	return conf, nil
 }
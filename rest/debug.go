@@ -0,0 +1,309 @@
+package rest
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/ultraman/go-common/logger"
+)
+
+// DebugLevel 调试日志的详细程度, 高级别包含低级别的内容
+type DebugLevel int
+
+const (
+	DebugNone DebugLevel = iota
+	// DebugURL 请求方法, URL, 状态码与耗时
+	DebugURL
+	// DebugHeaders 请求头, 响应头与可以复现请求的 curl 命令
+	DebugHeaders
+	// DebugBodies 请求体与响应体
+	DebugBodies
+)
+
+// DefaultDebugMaxBodySize 日志中请求体与响应体的最大长度
+const DefaultDebugMaxBodySize = 4096
+
+const redacted = "<masked>"
+
+var (
+	// DefaultRedactHeaders 始终脱敏的请求头与响应头
+	DefaultRedactHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie"}
+	// DefaultRedactFields 始终脱敏的 JSON 字段, 表单字段与查询参数
+	DefaultRedactFields = []string{"password", "secret", "client_secret", "token", "access_token", "refresh_token"}
+)
+
+// DebugConfig 调试日志配置
+type DebugConfig struct {
+	Level DebugLevel
+	// Logger 为空时使用 logger.DefaultLogger
+	Logger logger.Interface
+	// RedactHeaders 在 DefaultRedactHeaders 之外需要脱敏的头, 例如 X-Api-Key
+	RedactHeaders []string
+	// RedactFields 在 DefaultRedactFields 之外需要脱敏的字段, 不区分大小写
+	RedactFields []string
+	// MaxBodySize 默认为 DefaultDebugMaxBodySize
+	MaxBodySize int
+}
+
+// NewDebuggingRoundTripper 按照 config.Level 记录请求与响应, 敏感的头与字段会被脱敏
+func NewDebuggingRoundTripper(rt http.RoundTripper, config DebugConfig) http.RoundTripper {
+	if config.MaxBodySize <= 0 {
+		config.MaxBodySize = DefaultDebugMaxBodySize
+	}
+	d := &debuggingRoundTripper{
+		config:  config,
+		headers: make(map[string]bool),
+		fields:  make(map[string]bool),
+		rt:      rt,
+	}
+	for _, name := range append(append([]string(nil), DefaultRedactHeaders...), config.RedactHeaders...) {
+		d.headers[http.CanonicalHeaderKey(name)] = true
+	}
+	var patterns []string
+	for _, name := range append(append([]string(nil), DefaultRedactFields...), config.RedactFields...) {
+		d.fields[strings.ToLower(name)] = true
+		patterns = append(patterns, regexp.QuoteMeta(name))
+	}
+	// "field": "value" 或 "field": 123, 响应体被截断时也可以匹配
+	d.jsonField = regexp.MustCompile(`(?i)("(?:` + strings.Join(patterns, "|") + `)"\s*:\s*)("(?:[^"\\]|\\.)*"?|[^,}\]\s]+)`)
+	return d
+}
+
+type debuggingRoundTripper struct {
+	config    DebugConfig
+	headers   map[string]bool
+	fields    map[string]bool
+	jsonField *regexp.Regexp
+	rt        http.RoundTripper
+}
+
+func (d *debuggingRoundTripper) logger() logger.Interface {
+	if d.config.Logger != nil {
+		return d.config.Logger
+	}
+	return logger.DefaultLogger
+}
+
+func (d *debuggingRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	if d.config.Level <= DebugNone {
+		return d.rt.RoundTrip(req)
+	}
+	ctx := req.Context()
+	log := d.logger()
+	reqURL := d.redactURL(req.URL)
+
+	var reqBody string
+	var replayable bool
+	if d.config.Level >= DebugBodies {
+		reqBody, replayable = d.requestBody(req)
+	}
+	if d.config.Level >= DebugHeaders {
+		curlBody := ""
+		if replayable {
+			curlBody = reqBody
+		}
+		log.Info(ctx, "%s", d.curlCommand(req, reqURL, curlBody))
+		log.Info(ctx, "Request Headers:%s", d.formatHeaders(req.Header))
+	}
+	if d.config.Level >= DebugBodies && len(reqBody) > 0 {
+		log.Info(ctx, "Request Body: %s", reqBody)
+	}
+
+	start := time.Now()
+	resp, err := d.rt.RoundTrip(req)
+	latency := time.Since(start)
+	if err != nil {
+		log.Info(ctx, "%s %s failed in %d milliseconds: %v", req.Method, reqURL, latency.Milliseconds(), err)
+		return resp, err
+	}
+	log.Info(ctx, "%s %s %s in %d milliseconds", req.Method, reqURL, resp.Status, latency.Milliseconds())
+	if d.config.Level >= DebugHeaders {
+		log.Info(ctx, "Response Headers:%s", d.formatHeaders(resp.Header))
+	}
+	if d.config.Level >= DebugBodies && resp.Body != nil && resp.Body != http.NoBody {
+		// 响应体在读取完或关闭时记录, 不影响 Stream 与 Watch
+		resp.Body = &debugBody{
+			ReadCloser: resp.Body,
+			limit:      d.config.MaxBodySize,
+			report: func(body []byte, truncated bool) {
+				log.Info(ctx, "Response Body: %s", d.formatBody(resp.Header, body, truncated))
+			},
+		}
+	}
+	return resp, nil
+}
+
+// requestBody 只读取可以通过 GetBody 重放的请求体, 完整的文本请求体才会用于 curl 命令
+func (d *debuggingRoundTripper) requestBody(req *http.Request) (string, bool) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return "", false
+	}
+	if req.GetBody == nil {
+		return "<streaming body>", false
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return fmt.Sprintf("<unreadable body: %v>", err), false
+	}
+	defer body.Close()
+	data := make([]byte, d.config.MaxBodySize+1)
+	n, _ := io.ReadFull(body, data)
+	truncated := n > d.config.MaxBodySize
+	data = data[:minInt(n, d.config.MaxBodySize)]
+	replayable := !truncated && len(req.Header.Get("Content-Encoding")) == 0 && utf8.Valid(data)
+	return d.formatBody(req.Header, data, truncated), replayable
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func (d *debuggingRoundTripper) formatBody(header http.Header, body []byte, truncated bool) string {
+	if encoding := header.Get("Content-Encoding"); len(encoding) > 0 {
+		return fmt.Sprintf("<%d bytes, Content-Encoding %s>", len(body), encoding)
+	}
+	if !utf8.Valid(body) {
+		return fmt.Sprintf("<%d bytes binary>", len(body))
+	}
+	text := string(body)
+	switch mt := mediaType(header.Get("Content-Type")); {
+	case mt == "application/x-www-form-urlencoded":
+		text = d.redactForm(text)
+	case isJSONMediaType(mt) || mt == "application/x-ndjson":
+		text = d.jsonField.ReplaceAllString(text, `${1}"`+redacted+`"`)
+	}
+	if truncated {
+		text += "...(truncated)"
+	}
+	return text
+}
+
+// redactForm 不重新编码未脱敏的字段, 保持原始顺序
+func (d *debuggingRoundTripper) redactForm(form string) string {
+	pairs := strings.Split(form, "&")
+	for i, pair := range pairs {
+		key := pair
+		if j := strings.IndexByte(pair, '='); j >= 0 {
+			key = pair[:j]
+		}
+		if name, err := url.QueryUnescape(key); err == nil && d.fields[strings.ToLower(name)] {
+			pairs[i] = key + "=" + url.QueryEscape(redacted)
+		}
+	}
+	return strings.Join(pairs, "&")
+}
+
+func (d *debuggingRoundTripper) redactURL(u *url.URL) string {
+	redactedURL := *u
+	redactedURL.User = nil
+	if len(u.RawQuery) > 0 {
+		redactedURL.RawQuery = d.redactForm(u.RawQuery)
+	}
+	return redactedURL.String()
+}
+
+func (d *debuggingRoundTripper) headerValue(name, value string) string {
+	if !d.headers[http.CanonicalHeaderKey(name)] {
+		return value
+	}
+	// Authorization 保留认证方式
+	if i := strings.IndexByte(value, ' '); i > 0 && strings.HasSuffix(strings.ToLower(name), "authorization") {
+		return value[:i] + " " + redacted
+	}
+	return redacted
+}
+
+func (d *debuggingRoundTripper) formatHeaders(header http.Header) string {
+	var buf strings.Builder
+	for _, name := range sortedHeaderNames(header) {
+		for _, value := range header[name] {
+			fmt.Fprintf(&buf, "\n    %s: %s", name, d.headerValue(name, value))
+		}
+	}
+	return buf.String()
+}
+
+// curlCommand 生成复现请求的 curl 命令, 脱敏的值需要手动替换
+func (d *debuggingRoundTripper) curlCommand(req *http.Request, reqURL, body string) string {
+	var buf strings.Builder
+	fmt.Fprintf(&buf, "curl -v -X%s", req.Method)
+	for _, name := range sortedHeaderNames(req.Header) {
+		for _, value := range req.Header[name] {
+			fmt.Fprintf(&buf, " -H %s", shellQuote(name+": "+d.headerValue(name, value)))
+		}
+	}
+	if len(body) > 0 {
+		fmt.Fprintf(&buf, " --data-binary %s", shellQuote(body))
+	}
+	fmt.Fprintf(&buf, " %s", shellQuote(reqURL))
+	return buf.String()
+}
+
+func sortedHeaderNames(header http.Header) []string {
+	names := make([]string, 0, len(header))
+	for name := range header {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func shellQuote(s string) string {
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
+
+// debugBody 保留响应体的前 limit 个字节, 在 EOF 或关闭时回调一次
+type debugBody struct {
+	io.ReadCloser
+	limit  int
+	report func(body []byte, truncated bool)
+
+	mu        sync.Mutex
+	buf       bytes.Buffer
+	truncated bool
+	once      sync.Once
+}
+
+func (b *debugBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.mu.Lock()
+	if remaining := b.limit - b.buf.Len(); remaining > 0 {
+		b.buf.Write(p[:minInt(n, remaining)])
+		if n > remaining {
+			b.truncated = true
+		}
+	} else if n > 0 {
+		b.truncated = true
+	}
+	b.mu.Unlock()
+	if err == io.EOF {
+		b.flush()
+	}
+	return n, err
+}
+
+func (b *debugBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.flush()
+	return err
+}
+
+func (b *debugBody) flush() {
+	b.once.Do(func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		b.report(b.buf.Bytes(), b.truncated)
+	})
+}
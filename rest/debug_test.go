@@ -0,0 +1,148 @@
+package rest
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ultraman/go-common/logger"
+)
+
+type recordLogger struct {
+	mu    sync.Mutex
+	lines []string
+}
+
+var _ logger.Interface = &recordLogger{}
+
+func (l *recordLogger) Init(...logger.Option) error { return nil }
+func (l *recordLogger) Log(level logger.Level, args ...interface{}) {
+	l.record(fmt.Sprint(args...))
+}
+func (l *recordLogger) Logf(level logger.Level, message string, args ...interface{}) {
+	l.record(fmt.Sprintf(message, args...))
+}
+func (l *recordLogger) Info(ctx context.Context, message string, args ...interface{}) {
+	l.record(fmt.Sprintf(message, args...))
+}
+func (l *recordLogger) Error(ctx context.Context, message string, args ...interface{}) {
+	l.record(fmt.Sprintf(message, args...))
+}
+func (l *recordLogger) Warn(ctx context.Context, message string, args ...interface{}) {
+	l.record(fmt.Sprintf(message, args...))
+}
+func (l *recordLogger) Debug(ctx context.Context, message string, args ...interface{}) {
+	l.record(fmt.Sprintf(message, args...))
+}
+func (l *recordLogger) Fatal(ctx context.Context, message string, args ...interface{}) {
+	l.record(fmt.Sprintf(message, args...))
+}
+func (l *recordLogger) Trace(ctx context.Context, begin time.Time, f func() (string, int64), err error) {
+}
+
+func (l *recordLogger) record(line string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.lines = append(l.lines, line)
+}
+
+func (l *recordLogger) String() string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return strings.Join(l.lines, "\n")
+}
+
+func newDebugTestServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Set-Cookie", "session=abc")
+		_, _ = w.Write([]byte(`{"access_token":"server-secret","expires_in":3600,"name":"gopher"}`))
+	}))
+}
+
+func TestDebuggingRoundTripper_Levels(t *testing.T) {
+	server := newDebugTestServer()
+	defer server.Close()
+
+	for _, level := range []DebugLevel{DebugNone, DebugURL, DebugHeaders, DebugBodies} {
+		log := &recordLogger{}
+		client, err := NewRESTClientFor(&Config{
+			Host:        server.URL,
+			BearerToken: "bearer-secret",
+			Debug:       DebugConfig{Level: level, Logger: log},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := client.Post().Path("/users").Body([]byte(`{"name":"gopher"}`)).DoRaw(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		output := log.String()
+		expected := map[string]bool{
+			"POST " + server.URL + "/users 200 OK": level >= DebugURL,
+			"Request Headers:":                     level >= DebugHeaders,
+			"Response Headers:":                    level >= DebugHeaders,
+			"curl -v -XPOST":                       level >= DebugHeaders,
+			`Request Body: {"name":"gopher"}`:      level >= DebugBodies,
+			`--data-binary '{"name":"gopher"}'`:    level >= DebugBodies,
+			`Response Body: {"access_token":"<masked>","expires_in":3600,"name":"gopher"}`: level >= DebugBodies,
+		}
+		for text, present := range expected {
+			if strings.Contains(output, text) != present {
+				t.Errorf("level %d: expected %q present=%v in\n%s", level, text, present, output)
+			}
+		}
+		for _, secret := range []string{"bearer-secret", "server-secret", "session=abc"} {
+			if strings.Contains(output, secret) {
+				t.Errorf("level %d: %q leaked in\n%s", level, secret, output)
+			}
+		}
+	}
+}
+
+func TestDebuggingRoundTripper_Redact(t *testing.T) {
+	server := newDebugTestServer()
+	defer server.Close()
+
+	log := &recordLogger{}
+	rt := NewDebuggingRoundTripper(http.DefaultTransport, DebugConfig{
+		Level:         DebugBodies,
+		Logger:        log,
+		RedactHeaders: []string{"X-Api-Key"},
+		RedactFields:  []string{"pin"},
+	})
+	req, err := http.NewRequest(http.MethodPost, server.URL+"/login?token=query-secret&page=1", strings.NewReader("user=a&password=form-secret&PIN=1234"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("X-Api-Key", "key-secret")
+	req.Header.Set("Authorization", "Basic basic-secret")
+	resp, err := rt.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = ioutil.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+
+	output := log.String()
+	for _, secret := range []string{"query-secret", "form-secret", "1234", "key-secret", "basic-secret"} {
+		if strings.Contains(output, secret) {
+			t.Errorf("%q leaked in\n%s", secret, output)
+		}
+	}
+	for _, text := range []string{"Authorization: Basic <masked>", "X-Api-Key: <masked>", "page=1", "user=a"} {
+		if !strings.Contains(output, text) {
+			t.Errorf("expected %q in\n%s", text, output)
+		}
+	}
+	if strings.Count(output, "Response Body:") != 1 {
+		t.Errorf("expected response body to be logged once\n%s", output)
+	}
+}
@@ -0,0 +1,182 @@
+package rest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"strings"
+)
+
+// ErrorDecoder 将非 2xx 响应体解码为结构化错误
+// 返回的错误需要通过 Unwrap 包装 status, 以便 IsNotFound 等方法可以继续使用, 可以使用 NewAPIError 与 NewProblemDetails 构造
+// 无法识别响应体时返回 nil, 此时 Result.Error() 返回 status 本身
+type ErrorDecoder interface {
+	Decode(contentType string, body []byte, status *StatusError) error
+}
+
+type ErrorDecoderFunc func(contentType string, body []byte, status *StatusError) error
+
+func (f ErrorDecoderFunc) Decode(contentType string, body []byte, status *StatusError) error {
+	return f(contentType, body, status)
+}
+
+// DefaultErrorDecoder 依次尝试 RFC 7807 与 code/message 格式
+var DefaultErrorDecoder = NewChainErrorDecoder(NewProblemErrorDecoder(), NewEnvelopeErrorDecoder("code", "message"))
+
+// NewChainErrorDecoder 依次调用 decoders, 返回第一个非 nil 的错误
+func NewChainErrorDecoder(decoders ...ErrorDecoder) ErrorDecoder {
+	return ErrorDecoderFunc(func(contentType string, body []byte, status *StatusError) error {
+		for _, decoder := range decoders {
+			if decoder == nil {
+				continue
+			}
+			if err := decoder.Decode(contentType, body, status); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// ProblemDetails RFC 7807 application/problem+json
+type ProblemDetails struct {
+	Type     string `json:"type,omitempty"`
+	Title    string `json:"title,omitempty"`
+	Status   int    `json:"status,omitempty"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+	// Extensions 标准字段以外的扩展字段
+	Extensions map[string]interface{} `json:"-"`
+
+	status *StatusError
+}
+
+// NewProblemDetails 构造包装 status 的 ProblemDetails, 用于自定义 ErrorDecoder
+func NewProblemDetails(status *StatusError, title, detail string) *ProblemDetails {
+	problem := &ProblemDetails{Title: title, Detail: detail, status: status}
+	if status != nil {
+		problem.Status = status.Code
+	}
+	return problem
+}
+
+func (p *ProblemDetails) Error() string {
+	msg := p.Title
+	if len(p.Detail) > 0 {
+		if len(msg) > 0 {
+			msg += ": "
+		}
+		msg += p.Detail
+	}
+	if len(msg) == 0 {
+		msg = p.Type
+	}
+	// 零值或自定义 ErrorDecoder 构造时没有 status
+	if p.status == nil {
+		return msg
+	}
+	return fmt.Sprintf("%s %s: %d %s", p.status.Method, p.status.URL, p.status.Code, msg)
+}
+
+func (p *ProblemDetails) Unwrap() error {
+	if p.status == nil {
+		return nil
+	}
+	return p.status
+}
+
+// NewProblemErrorDecoder 解码 Content-Type 为 application/problem+json 的响应
+func NewProblemErrorDecoder() ErrorDecoder {
+	return ErrorDecoderFunc(func(contentType string, body []byte, status *StatusError) error {
+		if mediaType(contentType) != "application/problem+json" {
+			return nil
+		}
+		problem := &ProblemDetails{status: status}
+		if err := json.Unmarshal(body, problem); err != nil {
+			return nil
+		}
+		var fields map[string]interface{}
+		if err := json.Unmarshal(body, &fields); err == nil {
+			for _, key := range []string{"type", "title", "status", "detail", "instance"} {
+				delete(fields, key)
+			}
+			if len(fields) > 0 {
+				problem.Extensions = fields
+			}
+		}
+		if problem.Status == 0 {
+			problem.Status = status.Code
+		}
+		return problem
+	})
+}
+
+// APIError {"code": .., "message": ..} 格式的错误
+type APIError struct {
+	Code    string
+	Message string
+
+	status *StatusError
+}
+
+// NewAPIError 构造包装 status 的 APIError, 用于自定义 ErrorDecoder
+func NewAPIError(status *StatusError, code, message string) *APIError {
+	return &APIError{Code: code, Message: message, status: status}
+}
+
+func (e *APIError) Error() string {
+	msg := fmt.Sprintf("code=%s message=%s", e.Code, e.Message)
+	if e.status == nil {
+		return msg
+	}
+	return fmt.Sprintf("%s %s: %d %s", e.status.Method, e.status.URL, e.status.Code, msg)
+}
+
+func (e *APIError) Unwrap() error {
+	if e.status == nil {
+		return nil
+	}
+	return e.status
+}
+
+// NewEnvelopeErrorDecoder 解码 JSON 响应中的 code 与 message 字段, 字段名可以自定义
+func NewEnvelopeErrorDecoder(codeField, messageField string) ErrorDecoder {
+	return ErrorDecoderFunc(func(contentType string, body []byte, status *StatusError) error {
+		if !isJSONMediaType(mediaType(contentType)) {
+			return nil
+		}
+		var fields map[string]interface{}
+		decoder := json.NewDecoder(bytes.NewReader(body))
+		decoder.UseNumber()
+		if err := decoder.Decode(&fields); err != nil {
+			return nil
+		}
+		code, hasCode := fields[codeField]
+		message, hasMessage := fields[messageField]
+		if !hasCode && !hasMessage {
+			return nil
+		}
+		apiErr := NewAPIError(status, "", "")
+		if hasCode && code != nil {
+			apiErr.Code = fmt.Sprint(code)
+		}
+		if hasMessage && message != nil {
+			apiErr.Message = fmt.Sprint(message)
+		}
+		return apiErr
+	})
+}
+
+// mediaType 去掉 Content-Type 中的参数
+func mediaType(contentType string) string {
+	mt, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return strings.ToLower(strings.TrimSpace(contentType))
+	}
+	return mt
+}
+
+func isJSONMediaType(mt string) bool {
+	return mt == "application/json" || strings.HasSuffix(mt, "+json")
+}
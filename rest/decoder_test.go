@@ -0,0 +1,110 @@
+package rest
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestErrorDecoder(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/problem":
+			w.Header().Set("Content-Type", "application/problem+json")
+			w.WriteHeader(http.StatusForbidden)
+			_, _ = w.Write([]byte(`{"type":"https://example.com/out-of-credit","title":"You do not have enough credit.","detail":"Your current balance is 30","balance":30}`))
+		case "/envelope":
+			w.Header().Set("Content-Type", "application/json;charset=UTF-8")
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"code":1000001,"message":"invalid user name"}`))
+		default:
+			w.Header().Set("Content-Type", "text/plain")
+			w.WriteHeader(http.StatusInternalServerError)
+			_, _ = w.Write([]byte("internal error"))
+		}
+	}))
+	defer server.Close()
+
+	client, _ := NewRESTClientFor(&Config{Host: server.URL})
+
+	err := client.Get().Path("/problem").Do(context.Background()).Error()
+	var problem *ProblemDetails
+	if !errors.As(err, &problem) {
+		t.Fatalf("expected *ProblemDetails, got %T", err)
+	}
+	if problem.Title != "You do not have enough credit." || problem.Status != http.StatusForbidden || problem.Extensions["balance"] != float64(30) {
+		t.Fatalf("unexpected problem %+v", problem)
+	}
+	if !IsForbidden(err) {
+		t.Fatal("expected problem to wrap the status error")
+	}
+
+	err = client.Get().Path("/envelope").Do(context.Background()).Error()
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("expected *APIError, got %T", err)
+	}
+	if apiErr.Code != "1000001" || apiErr.Message != "invalid user name" {
+		t.Fatalf("unexpected api error %+v", apiErr)
+	}
+	if StatusCodeForError(err) != http.StatusBadRequest {
+		t.Fatal("expected api error to wrap the status error")
+	}
+
+	err = client.Get().Path("/text").Do(context.Background()).Error()
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) || errors.As(err, &apiErr) || statusErr.Body != "internal error" {
+		t.Fatalf("expected plain status error, got %v", err)
+	}
+}
+
+func TestErrorDecoder_Custom(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = w.Write([]byte(r.URL.Path[1:] + ":no such user"))
+	}))
+	defer server.Close()
+
+	// 自定义 ErrorDecoder 通过构造函数包装 status
+	decoder := ErrorDecoderFunc(func(contentType string, body []byte, status *StatusError) error {
+		parts := strings.SplitN(string(body), ":", 2)
+		if parts[0] == "problem" {
+			return NewProblemDetails(status, "Not Found", parts[1])
+		}
+		return NewAPIError(status, "user_not_found", parts[1])
+	})
+	client, _ := NewRESTClientFor(&Config{
+		Host:          server.URL,
+		ContentConfig: ContentConfig{ErrorDecoder: decoder},
+	})
+	err := client.Get().Path("/api").Do(context.Background()).Error()
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.Message != "no such user" || !IsNotFound(err) {
+		t.Fatalf("unexpected error %v", err)
+	}
+	err = client.Get().Path("/problem").Do(context.Background()).Error()
+	var problem *ProblemDetails
+	if !errors.As(err, &problem) || problem.Status != http.StatusNotFound || problem.Detail != "no such user" || !IsNotFound(err) {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
+
+func TestErrorDecoder_ZeroValue(t *testing.T) {
+	problem := &ProblemDetails{Title: "Not Found"}
+	if msg := problem.Error(); msg != "Not Found" {
+		t.Errorf("unexpected message %q", msg)
+	}
+	if (&ProblemDetails{}).Error() != "" || errors.Unwrap(&ProblemDetails{}) != nil {
+		t.Error("expected zero ProblemDetails not to panic")
+	}
+	apiErr := &APIError{Code: "1", Message: "failed"}
+	if msg := apiErr.Error(); msg != "code=1 message=failed" {
+		t.Errorf("unexpected message %q", msg)
+	}
+	if IsNotFound(&APIError{}) || errors.Unwrap(&APIError{}) != nil {
+		t.Error("expected zero APIError not to wrap a StatusError")
+	}
+}
@@ -0,0 +1,172 @@
+package rest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"unicode/utf8"
+)
+
+var (
+	// ErrRequestTimeout 客户端超时, 包括 Request.Timeout, Config.Timeout 以及 ctx 的截止时间
+	ErrRequestTimeout = errors.New("request timeout")
+	// ErrRequestCanceled 调用方取消了 ctx
+	ErrRequestCanceled = errors.New("request canceled")
+)
+
+// RequestError 请求未能拿到响应时返回的错误
+// errors.Is(err, ErrRequestTimeout) / errors.Is(err, ErrRequestCanceled) 判断原因
+type RequestError struct {
+	Method string
+	URL    string
+	// Reason ErrRequestTimeout 或 ErrRequestCanceled
+	Reason error
+	Err    error
+}
+
+func (e *RequestError) Error() string {
+	return fmt.Sprintf("%s %s: %v: %v", e.Method, e.URL, e.Reason, e.Err)
+}
+
+func (e *RequestError) Unwrap() error {
+	return e.Err
+}
+
+func (e *RequestError) Is(target error) bool {
+	return target == e.Reason
+}
+
+// IsTimeout 是否为客户端超时
+func IsTimeout(err error) bool {
+	return errors.Is(err, ErrRequestTimeout)
+}
+
+// IsCanceled 是否为调用方取消
+func IsCanceled(err error) bool {
+	return errors.Is(err, ErrRequestCanceled)
+}
+
+// wrapRequestError 将超时与取消转换为 RequestError, 其余错误原样返回
+func wrapRequestError(ctx context.Context, method, url string, err error) error {
+	if err == nil {
+		return nil
+	}
+	var reqErr *RequestError
+	if errors.As(err, &reqErr) {
+		return err
+	}
+	var reason error
+	var netErr net.Error
+	switch {
+	case errors.Is(err, context.Canceled) || errors.Is(ctx.Err(), context.Canceled):
+		reason = ErrRequestCanceled
+	case errors.Is(err, context.DeadlineExceeded) || errors.Is(ctx.Err(), context.DeadlineExceeded):
+		reason = ErrRequestTimeout
+	case errors.As(err, &netErr) && netErr.Timeout():
+		reason = ErrRequestTimeout
+	default:
+		return err
+	}
+	return &RequestError{
+		Method: method,
+		URL:    url,
+		Reason: reason,
+		Err:    err,
+	}
+}
+
+// maxStatusErrorBodyLength StatusError 中保留的响应体长度
+const maxStatusErrorBodyLength = 1024
+
+// StatusError 非 2xx 响应返回的错误, 由 Result.Error() 与 Result.Into() 返回
+type StatusError struct {
+	Code   int
+	Method string
+	URL    string
+	// Body 截断后的响应体
+	Body string
+	// Details 服务端返回的错误信息, 使用 Codec 解码响应体, 解码失败时为 nil
+	Details interface{}
+}
+
+func newStatusError(req *http.Request, code int, body []byte, coder Marshaler) *StatusError {
+	err := &StatusError{
+		Code: code,
+	}
+	if req != nil {
+		err.Method = req.Method
+		err.URL = req.URL.String()
+	}
+	if len(body) > maxStatusErrorBodyLength {
+		// 不在多字节字符中间截断
+		end := maxStatusErrorBodyLength
+		for end > 0 && !utf8.RuneStart(body[end]) {
+			end--
+		}
+		err.Body = string(body[:end]) + "..."
+	} else {
+		err.Body = string(body)
+	}
+	if coder != nil && len(body) > 0 {
+		var details map[string]interface{}
+		if coder.Unmarshal(body, &details) == nil {
+			err.Details = details
+		}
+	}
+	return err
+}
+
+func (e *StatusError) Error() string {
+	msg := fmt.Sprintf("%s %s: %d %s", e.Method, e.URL, e.Code, http.StatusText(e.Code))
+	if len(e.Body) > 0 {
+		msg += ": " + e.Body
+	}
+	return msg
+}
+
+// StatusCodeForError 返回错误中的状态码, 不是 StatusError 时返回 0
+func StatusCodeForError(err error) int {
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.Code
+	}
+	return 0
+}
+
+// IsNotFound 404
+func IsNotFound(err error) bool {
+	return StatusCodeForError(err) == http.StatusNotFound
+}
+
+// IsConflict 409
+func IsConflict(err error) bool {
+	return StatusCodeForError(err) == http.StatusConflict
+}
+
+// IsUnauthorized 401
+func IsUnauthorized(err error) bool {
+	return StatusCodeForError(err) == http.StatusUnauthorized
+}
+
+// IsForbidden 403
+func IsForbidden(err error) bool {
+	return StatusCodeForError(err) == http.StatusForbidden
+}
+
+// IsTooManyRequests 429
+func IsTooManyRequests(err error) bool {
+	return StatusCodeForError(err) == http.StatusTooManyRequests
+}
+
+// IsServerTimeout 服务端超时, 408 或 504
+func IsServerTimeout(err error) bool {
+	code := StatusCodeForError(err)
+	return code == http.StatusRequestTimeout || code == http.StatusGatewayTimeout
+}
+
+// IsServerError 5xx
+func IsServerError(err error) bool {
+	return StatusCodeForError(err) >= http.StatusInternalServerError
+}
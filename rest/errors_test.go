@@ -0,0 +1,169 @@
+package rest
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+func newSlowServer(delay time.Duration) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(delay):
+		case <-r.Context().Done():
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+}
+
+func TestRequest_ContextDeadline(t *testing.T) {
+	server := newSlowServer(time.Second)
+	defer server.Close()
+
+	client, _ := NewRESTClientFor(&Config{Host: server.URL})
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err := client.Get().Path("/").DoRaw(ctx)
+	if !errors.Is(err, ErrRequestTimeout) || !IsTimeout(err) {
+		t.Fatalf("expected timeout error, got %v", err)
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected error to wrap context.DeadlineExceeded, got %v", err)
+	}
+	if IsCanceled(err) {
+		t.Fatal("timeout should not be reported as cancellation")
+	}
+	var reqErr *RequestError
+	if !errors.As(err, &reqErr) || reqErr.Method != "GET" {
+		t.Fatalf("expected *RequestError, got %T", err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Fatalf("expected request to stop at the deadline, took %v", elapsed)
+	}
+}
+
+func TestRequest_Timeout(t *testing.T) {
+	server := newSlowServer(time.Second)
+	defer server.Close()
+
+	client, _ := NewRESTClientFor(&Config{Host: server.URL})
+	_, err := client.Get().Path("/").Timeout(20 * time.Millisecond).DoRaw(context.Background())
+	if !IsTimeout(err) {
+		t.Fatalf("expected timeout error, got %v", err)
+	}
+}
+
+func TestRequest_Canceled(t *testing.T) {
+	server := newSlowServer(time.Second)
+	defer server.Close()
+
+	client, _ := NewRESTClientFor(&Config{Host: server.URL})
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+	_, err := client.Get().Path("/").DoRaw(ctx)
+	if !errors.Is(err, ErrRequestCanceled) || IsTimeout(err) {
+		t.Fatalf("expected canceled error, got %v", err)
+	}
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected error to wrap context.Canceled, got %v", err)
+	}
+}
+
+func TestRequest_NoTimeout(t *testing.T) {
+	server := newSlowServer(0)
+	defer server.Close()
+
+	client, _ := NewRESTClientFor(&Config{Host: server.URL})
+	result := client.Get().Path("/").Timeout(time.Second).Do(context.Background())
+	if err := result.Error(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestResult_StatusError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/missing":
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"message":"user not found"}`))
+		case "/conflict":
+			w.WriteHeader(http.StatusConflict)
+			_, _ = w.Write([]byte("already exists"))
+		default:
+			_, _ = w.Write([]byte(`{"name":"hello"}`))
+		}
+	}))
+	defer server.Close()
+
+	client, _ := NewRESTClientFor(&Config{
+		Host:          server.URL,
+		ContentConfig: ContentConfig{Codec: NewJsonMarshaler()},
+	})
+
+	user := &struct {
+		Name string `json:"name"`
+	}{}
+	err := client.Get().Path("/missing").Do(context.Background()).Into(user)
+	if !IsNotFound(err) || IsConflict(err) {
+		t.Fatalf("expected not found error, got %v", err)
+	}
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) {
+		t.Fatalf("expected *StatusError, got %T", err)
+	}
+	if statusErr.Method != "GET" || statusErr.URL != server.URL+"/missing" {
+		t.Fatalf("unexpected request info %s %s", statusErr.Method, statusErr.URL)
+	}
+	details, ok := statusErr.Details.(map[string]interface{})
+	if !ok || details["message"] != "user not found" {
+		t.Fatalf("unexpected details %v", statusErr.Details)
+	}
+	if len(user.Name) != 0 {
+		t.Fatal("error body should not be decoded into the result object")
+	}
+
+	result := client.Get().Path("/conflict").Do(context.Background())
+	if !IsConflict(result.Error()) || StatusCodeForError(result.Error()) != http.StatusConflict {
+		t.Fatalf("expected conflict error, got %v", result.Error())
+	}
+	if body, _ := result.Raw(); string(body) != "already exists" {
+		t.Fatalf("expected raw body to be kept, got %q", body)
+	}
+
+	if err := client.Get().Path("/").Do(context.Background()).Into(user); err != nil || user.Name != "hello" {
+		t.Fatalf("unexpected result %v %v", err, user)
+	}
+}
+
+func TestStatusError_TruncatedBody(t *testing.T) {
+	body := make([]byte, maxStatusErrorBodyLength*2)
+	for i := range body {
+		body[i] = 'x'
+	}
+	err := newStatusError(nil, http.StatusInternalServerError, body, nil)
+	if len(err.Body) != maxStatusErrorBodyLength+3 {
+		t.Fatalf("expected truncated body, got %d bytes", len(err.Body))
+	}
+	// 截断位置落在多字节字符中间时退回到字符边界
+	multibyte := append([]byte("a"), []byte(strings.Repeat("中", maxStatusErrorBodyLength))...)
+	truncated := newStatusError(nil, http.StatusInternalServerError, multibyte, nil)
+	if !utf8.ValidString(truncated.Body) || len(truncated.Body) > maxStatusErrorBodyLength+3 {
+		t.Fatalf("expected body truncated at a rune boundary, got %d bytes", len(truncated.Body))
+	}
+	if !IsServerError(err) || IsServerTimeout(err) {
+		t.Fatal("unexpected status helpers result")
+	}
+	if !IsServerTimeout(&StatusError{Code: http.StatusGatewayTimeout}) {
+		t.Fatal("expected 504 to be a server timeout")
+	}
+	if !IsTooManyRequests(&StatusError{Code: http.StatusTooManyRequests}) || !IsUnauthorized(&StatusError{Code: http.StatusUnauthorized}) {
+		t.Fatal("unexpected status helpers result")
+	}
+}
@@ -0,0 +1,268 @@
+package rest
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// hedgeLatencySamples 每个路由保留的最近延迟样本数
+	hedgeLatencySamples = 128
+	// hedgeMinSamples 样本数达到该值才使用 Percentile 计算延迟
+	hedgeMinSamples = 20
+	// hedgeMaxRoutes 最多统计的路径数, 超过后新的路径不再记录, 使用 Delay
+	hedgeMaxRoutes = 1024
+)
+
+// HedgePolicy 请求对冲, 在 Delay 之后仍未收到响应时发送重复请求, 使用第一个成功的响应
+type HedgePolicy struct {
+	// Delay 发送对冲请求前的等待时间
+	Delay time.Duration
+	// Percentile 大于 0 时使用该路径模板最近延迟的百分位 (0-100) 作为等待时间, 样本不足时使用 Delay
+	Percentile float64
+	// MaxHedges 最多额外发送的请求数, 默认为 1
+	MaxHedges int
+	// NonIdempotent 允许对 POST, PATCH 等非幂等请求进行对冲
+	NonIdempotent bool
+}
+
+// HedgeMetrics Metrics 实现该接口时记录对冲请求, won 表示对冲请求的响应被采用
+type HedgeMetrics interface {
+	RequestHedge(ctx context.Context, info RequestInfo, won bool)
+}
+
+// Hedge 为请求开启对冲, 对冲请求需要从限流器获取令牌, 获取失败时不发送
+// 每个对冲请求单独经过 Transport 的各层包装, 落败后被取消的请求不计入熔断统计
+func (r *Request) Hedge(policy HedgePolicy) *Request {
+	if r.err != nil {
+		return r
+	}
+	if policy.Delay <= 0 && policy.Percentile <= 0 {
+		r.err = errors.New("hedge policy requires a delay or a percentile")
+		return r
+	}
+	if policy.Percentile > 100 {
+		r.err = errors.New("hedge percentile must be in (0, 100]")
+		return r
+	}
+	if policy.MaxHedges <= 0 {
+		policy.MaxHedges = 1
+	}
+	r.hedge = &policy
+	return r
+}
+
+// canHedge 仅对幂等请求对冲, 请求体需要可以通过 GetBody 重放
+func (r *Request) canHedge(req *http.Request) bool {
+	if r.hedge == nil {
+		return false
+	}
+	if !isIdempotent(req) && !r.hedge.NonIdempotent {
+		return false
+	}
+	return req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+}
+
+type hedgeResult struct {
+	index int
+	resp  *http.Response
+	err   error
+}
+
+func (h hedgeResult) ok() bool {
+	return h.err == nil && h.resp.StatusCode < http.StatusInternalServerError
+}
+
+// hedgedDo 发送请求与对冲请求, 返回第一个成功的响应并取消其余请求
+// 每个请求使用单独的子 span 与 traceparent, 全部失败时返回最后一个结果, 交给重试策略处理
+func (r *Request) hedgedDo(ctx context.Context, client *http.Client, req *http.Request, info RequestInfo) (*http.Response, error) {
+	policy := r.hedge
+	start := time.Now()
+	// 按路径模板统计延迟, 没有模板的路径各自统计, 不使用合并后的 Route 标签
+	route := r.template()
+	delay, ok := r.c.latencies.delay(route, policy)
+	if !ok {
+		// 样本不足且没有 Delay 时只记录延迟
+		resp, err := client.Do(req)
+		if (hedgeResult{resp: resp, err: err}).ok() {
+			r.c.latencies.record(route, time.Since(start))
+		}
+		return resp, err
+	}
+
+	results := make(chan hedgeResult, policy.MaxHedges+1)
+	var cancels []context.CancelFunc
+	launch := func(req *http.Request) {
+		attemptCtx, cancel := context.WithCancel(ctx)
+		index := len(cancels)
+		cancels = append(cancels, cancel)
+		attemptCtx, span := r.startHedgeSpan(attemptCtx, index)
+		r.injectTrace(attemptCtx, req)
+		go func() {
+			resp, err := client.Do(req.WithContext(attemptCtx))
+			code := 0
+			if resp != nil {
+				code = resp.StatusCode
+			}
+			endSpan(span, code, err)
+			results <- hedgeResult{index: index, resp: resp, err: err}
+		}()
+	}
+	launch(req)
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	pending := 1
+	var result hedgeResult
+	for {
+		select {
+		case <-timer.C:
+			// 限流器没有余量时不再发送对冲请求
+			if r.rateLimiter != nil && !r.rateLimiter.TryAccept() {
+				continue
+			}
+			hedge, err := newHedgeRequest(ctx, req)
+			if err != nil {
+				continue
+			}
+			countRequestBody(ctx, r.metrics, info, hedge)
+			launch(hedge)
+			pending++
+			if len(cancels)-1 < policy.MaxHedges {
+				timer.Reset(delay)
+			}
+			continue
+		case result = <-results:
+			pending--
+		}
+		if result.ok() || pending == 0 {
+			break
+		}
+		if result.resp != nil {
+			readAndCloseResponseBody(result.resp)
+		}
+		cancels[result.index]()
+	}
+
+	// 只记录第一个请求的延迟: 对冲请求胜出时第一个请求已被取消, 记录它至少等待的时间, 不使用对冲请求自身的延迟
+	if result.ok() {
+		r.c.latencies.record(route, time.Since(start))
+	}
+	if metrics, ok := r.metrics.(HedgeMetrics); ok {
+		for i := 1; i < len(cancels); i++ {
+			metrics.RequestHedge(ctx, info, i == result.index && result.ok())
+		}
+	}
+	for i, cancel := range cancels {
+		if i != result.index {
+			cancel()
+		}
+	}
+	// 被取消的请求在后台关闭响应体
+	go func(pending int) {
+		for i := 0; i < pending; i++ {
+			if loser := <-results; loser.resp != nil {
+				readAndCloseResponseBody(loser.resp)
+			}
+		}
+	}(pending)
+
+	winner := cancels[result.index]
+	if result.err != nil {
+		winner()
+		return nil, result.err
+	}
+	result.resp.Body = &cancelReadCloser{ReadCloser: result.resp.Body, cancel: winner}
+	return result.resp, nil
+}
+
+// newHedgeRequest 复制请求并通过 GetBody 获取新的请求体
+func newHedgeRequest(ctx context.Context, req *http.Request) (*http.Request, error) {
+	hedge := req.Clone(ctx)
+	if req.Body != nil && req.Body != http.NoBody {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		hedge.Body = body
+	}
+	return hedge, nil
+}
+
+// cancelReadCloser 关闭响应体时取消对应的 context
+type cancelReadCloser struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (c *cancelReadCloser) Close() error {
+	err := c.ReadCloser.Close()
+	c.cancel()
+	return err
+}
+
+// latencyTracker 按路径模板记录最近的请求延迟, 用于计算对冲等待时间
+type latencyTracker struct {
+	mu     sync.Mutex
+	routes map[string]*latencyWindow
+}
+
+type latencyWindow struct {
+	samples []time.Duration
+	next    int
+}
+
+func newLatencyTracker() *latencyTracker {
+	return &latencyTracker{routes: make(map[string]*latencyWindow)}
+}
+
+func (t *latencyTracker) record(route string, latency time.Duration) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	window, ok := t.routes[route]
+	if !ok {
+		if len(t.routes) >= hedgeMaxRoutes {
+			return
+		}
+		window = &latencyWindow{}
+		t.routes[route] = window
+	}
+	if len(window.samples) < hedgeLatencySamples {
+		window.samples = append(window.samples, latency)
+		return
+	}
+	window.samples[window.next] = latency
+	window.next = (window.next + 1) % hedgeLatencySamples
+}
+
+// delay 返回对冲等待时间, 没有可用的等待时间时返回 false
+func (t *latencyTracker) delay(route string, policy *HedgePolicy) (time.Duration, bool) {
+	if t != nil && policy.Percentile > 0 {
+		t.mu.Lock()
+		var samples []time.Duration
+		if window, ok := t.routes[route]; ok && len(window.samples) >= hedgeMinSamples {
+			samples = append(samples, window.samples...)
+		}
+		t.mu.Unlock()
+		if len(samples) > 0 {
+			sort.Slice(samples, func(i, j int) bool { return samples[i] < samples[j] })
+			index := int(float64(len(samples))*policy.Percentile/100+0.5) - 1
+			if index < 0 {
+				index = 0
+			}
+			if index >= len(samples) {
+				index = len(samples) - 1
+			}
+			return samples[index], true
+		}
+	}
+	return policy.Delay, policy.Delay > 0
+}
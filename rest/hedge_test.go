@@ -0,0 +1,217 @@
+package rest
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type hedgeMetrics struct {
+	NoopMetrics
+	mu    sync.Mutex
+	hedge []bool
+}
+
+func (m *hedgeMetrics) RequestHedge(ctx context.Context, info RequestInfo, won bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.hedge = append(m.hedge, won)
+}
+
+func (m *hedgeMetrics) hedges() []bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]bool(nil), m.hedge...)
+}
+
+// denyRateLimiter 不阻塞 Wait, TryAccept 始终失败
+type denyRateLimiter struct {
+	tries int32
+}
+
+func (l *denyRateLimiter) TryAccept() bool {
+	atomic.AddInt32(&l.tries, 1)
+	return false
+}
+func (l *denyRateLimiter) Accept()                        {}
+func (l *denyRateLimiter) Stop()                          {}
+func (l *denyRateLimiter) QPS() float32                   { return 0 }
+func (l *denyRateLimiter) Wait(ctx context.Context) error { return nil }
+
+// newSlowFirstServer 第一个请求阻塞直到被取消, canceled 在第一个请求被取消时关闭
+func newSlowFirstServer(t *testing.T) (*httptest.Server, *int32, chan struct{}) {
+	var calls int32
+	canceled := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			select {
+			case <-r.Context().Done():
+				close(canceled)
+				return
+			case <-time.After(2 * time.Second):
+			}
+		}
+		_, _ = w.Write([]byte("ok"))
+	}))
+	t.Cleanup(server.Close)
+	return server, &calls, canceled
+}
+
+func TestRequest_Hedge(t *testing.T) {
+	server, calls, canceled := newSlowFirstServer(t)
+	metrics := &hedgeMetrics{}
+	client, err := NewRESTClientFor(&Config{Host: server.URL, Metrics: metrics})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	body, err := client.Get().Path("/").Hedge(HedgePolicy{Delay: 50 * time.Millisecond}).Do(context.Background()).Raw()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(body) != "ok" {
+		t.Errorf("unexpected body %q", body)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("expected hedge to win, took %s", elapsed)
+	}
+	if n := atomic.LoadInt32(calls); n != 2 {
+		t.Errorf("expected 2 calls, got %d", n)
+	}
+	select {
+	case <-canceled:
+	case <-time.After(time.Second):
+		t.Error("expected slow request to be canceled")
+	}
+	if hedges := metrics.hedges(); len(hedges) != 1 || !hedges[0] {
+		t.Errorf("expected one winning hedge, got %v", hedges)
+	}
+}
+
+func TestRequest_HedgeNonIdempotent(t *testing.T) {
+	server, calls, _ := newSlowFirstServer(t)
+	client, err := NewRESTClientFor(&Config{Host: server.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+	err = client.Post().Path("/").Body([]byte("a")).Hedge(HedgePolicy{Delay: 10 * time.Millisecond}).Do(ctx).Error()
+	if err == nil {
+		t.Fatal("expected POST without opt-in not to be hedged")
+	}
+	if n := atomic.LoadInt32(calls); n != 1 {
+		t.Errorf("expected 1 call, got %d", n)
+	}
+
+	// 显式开启后请求体可以重放
+	policy := HedgePolicy{Delay: 10 * time.Millisecond, NonIdempotent: true}
+	if err := client.Post().Path("/").Body([]byte("a")).Hedge(policy).Do(context.Background()).Error(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRequest_HedgeRateLimiter(t *testing.T) {
+	server, calls, _ := newSlowFirstServer(t)
+	limiter := &denyRateLimiter{}
+	client, err := NewRESTClientFor(&Config{Host: server.URL, RateLimiter: limiter})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+	_ = client.Get().Path("/").Hedge(HedgePolicy{Delay: 10 * time.Millisecond, MaxHedges: 3}).Do(ctx).Error()
+	if n := atomic.LoadInt32(calls); n != 1 {
+		t.Errorf("expected hedges to be blocked by rate limiter, got %d calls", n)
+	}
+	if n := atomic.LoadInt32(&limiter.tries); n != 1 {
+		t.Errorf("expected 1 TryAccept, got %d", n)
+	}
+}
+
+func TestRequest_HedgeInvalidPolicy(t *testing.T) {
+	client, err := NewRESTClientFor(&Config{Host: "http://localhost"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, policy := range []HedgePolicy{{}, {Percentile: 101}} {
+		if err := client.Get().Hedge(policy).Do(context.Background()).Error(); err == nil {
+			t.Errorf("expected error for %+v", policy)
+		}
+	}
+}
+
+func TestRequest_HedgeLatency(t *testing.T) {
+	server, _, _ := newSlowFirstServer(t)
+	client, err := NewRESTClientFor(&Config{Host: server.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	delay := 50 * time.Millisecond
+	if err := client.Get().Path("/").Hedge(HedgePolicy{Delay: delay}).Do(context.Background()).Error(); err != nil {
+		t.Fatal(err)
+	}
+	// 对冲请求胜出时记录第一个请求已经等待的时间, 而不是对冲请求自身的延迟
+	tracker := client.(*Client).latencies
+	tracker.mu.Lock()
+	defer tracker.mu.Unlock()
+	samples := tracker.routes["/"].samples
+	if len(samples) != 1 || samples[0] < delay {
+		t.Fatalf("expected the first attempt latency to be recorded, got %v", samples)
+	}
+}
+
+func TestRequest_HedgeLatencyPerPath(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			time.Sleep(30 * time.Millisecond)
+		}
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+	client, err := NewRESTClientFor(&Config{Host: server.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 没有路径模板的路径分别统计延迟, 不会混在 UntemplatedRoute 中
+	policy := HedgePolicy{Delay: time.Second, Percentile: 50}
+	for i := 0; i < hedgeMinSamples; i++ {
+		for _, path := range []string{"/fast", "/slow"} {
+			if err := client.Get().Path(path).Hedge(policy).Do(context.Background()).Error(); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	tracker := client.(*Client).latencies
+	fast, _ := tracker.delay("/fast", &policy)
+	slow, _ := tracker.delay("/slow", &policy)
+	if fast >= 30*time.Millisecond || slow < 30*time.Millisecond {
+		t.Fatalf("expected separate latencies per path, got fast %s slow %s", fast, slow)
+	}
+}
+
+func TestLatencyTracker(t *testing.T) {
+	tracker := newLatencyTracker()
+	policy := &HedgePolicy{Delay: time.Second, Percentile: 90}
+	if delay, ok := tracker.delay("/a", policy); !ok || delay != time.Second {
+		t.Errorf("expected fallback delay, got %s %v", delay, ok)
+	}
+	for i := 1; i <= hedgeLatencySamples+100; i++ {
+		tracker.record("/a", time.Duration(i)*time.Millisecond)
+	}
+	// 只保留最近的 128 个样本: 101ms - 228ms
+	if delay, ok := tracker.delay("/a", policy); !ok || delay != 215*time.Millisecond {
+		t.Errorf("expected p90 215ms, got %s %v", delay, ok)
+	}
+	if _, ok := tracker.delay("/b", &HedgePolicy{Percentile: 90}); ok {
+		t.Error("expected no delay without samples")
+	}
+}
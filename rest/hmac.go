@@ -0,0 +1,273 @@
+package rest
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	HMACSHA1   = "HMAC-SHA1"
+	HMACSHA256 = "HMAC-SHA256"
+	HMACSHA512 = "HMAC-SHA512"
+)
+
+// CanonicalRequest 参与签名的请求信息
+type CanonicalRequest struct {
+	Method string
+	// Path 转义后的路径
+	Path string
+	// Query 按 key, value 排序后的查询参数
+	Query string
+	// Headers 参与签名的请求头, 按名称排序, 格式为 name:value
+	Headers []string
+	// SignedHeaders 参与签名的请求头名称, 小写并排序
+	SignedHeaders []string
+	Timestamp     string
+	// BodyHash 请求体摘要的十六进制编码, 与签名使用相同的哈希算法
+	BodyHash string
+}
+
+// DefaultCanonicalize 各部分使用换行连接
+func DefaultCanonicalize(c *CanonicalRequest) string {
+	parts := []string{c.Method, c.Path, c.Query}
+	parts = append(parts, c.Headers...)
+	parts = append(parts, strings.Join(c.SignedHeaders, ";"), c.Timestamp, c.BodyHash)
+	return strings.Join(parts, "\n")
+}
+
+// HMACConfig 请求签名配置
+type HMACConfig struct {
+	KeyID  string
+	Secret []byte `datapolicy:"security-key"`
+	// Algorithm 默认为 HMAC-SHA256
+	Algorithm string
+	// SignedHeaders 参与签名的请求头
+	SignedHeaders []string
+	// Canonicalize 生成待签名字符串, 默认为 DefaultCanonicalize
+	Canonicalize func(c *CanonicalRequest) string
+
+	// 请求头名称, 为空时使用默认值
+	KeyIDHeader     string
+	TimestampHeader string
+	BodyHashHeader  string
+	SignatureHeader string
+	AlgorithmHeader string
+
+	// Now 用于生成时间戳, 默认为 time.Now
+	Now func() time.Time
+}
+
+func (c *HMACConfig) complete() error {
+	if len(c.Secret) == 0 {
+		return errors.New("hmac: secret is required")
+	}
+	if len(c.Algorithm) == 0 {
+		c.Algorithm = HMACSHA256
+	}
+	if hashFor(c.Algorithm) == nil {
+		return fmt.Errorf("hmac: unsupported algorithm %q", c.Algorithm)
+	}
+	if c.Canonicalize == nil {
+		c.Canonicalize = DefaultCanonicalize
+	}
+	if len(c.KeyIDHeader) == 0 {
+		c.KeyIDHeader = "X-Key-Id"
+	}
+	if len(c.TimestampHeader) == 0 {
+		c.TimestampHeader = "X-Timestamp"
+	}
+	if len(c.BodyHashHeader) == 0 {
+		c.BodyHashHeader = "X-Content-Hash"
+	}
+	if len(c.SignatureHeader) == 0 {
+		c.SignatureHeader = "X-Signature"
+	}
+	if len(c.AlgorithmHeader) == 0 {
+		c.AlgorithmHeader = "X-Signature-Method"
+	}
+	if c.Now == nil {
+		c.Now = time.Now
+	}
+	return nil
+}
+
+func hashFor(algorithm string) func() hash.Hash {
+	switch strings.ToUpper(algorithm) {
+	case HMACSHA1:
+		return sha1.New
+	case HMACSHA256:
+		return sha256.New
+	case HMACSHA512:
+		return sha512.New
+	}
+	return nil
+}
+
+// NewHMACSigner 返回对请求进行 HMAC 签名的 WrapperFunc, 可以通过 TransportConfig.Wrap 组合
+func NewHMACSigner(config HMACConfig) (WrapperFunc, error) {
+	if err := config.complete(); err != nil {
+		return nil, err
+	}
+	return func(rt http.RoundTripper) http.RoundTripper {
+		return &hmacRoundTripper{config: config, rt: rt}
+	}, nil
+}
+
+type hmacRoundTripper struct {
+	config HMACConfig
+	rt     http.RoundTripper
+}
+
+func (rt *hmacRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = CloneRequest(req)
+	body, err := readRequestBody(req)
+	if err != nil {
+		return nil, err
+	}
+
+	c := rt.config
+	newHash := hashFor(c.Algorithm)
+	bodyHash := newHash()
+	bodyHash.Write(body)
+
+	timestamp := strconv.FormatInt(c.Now().Unix(), 10)
+	req.Header.Set(c.KeyIDHeader, c.KeyID)
+	req.Header.Set(c.TimestampHeader, timestamp)
+	req.Header.Set(c.BodyHashHeader, hex.EncodeToString(bodyHash.Sum(nil)))
+	req.Header.Set(c.AlgorithmHeader, c.Algorithm)
+
+	canonical := canonicalRequest(req, c.SignedHeaders)
+	canonical.Timestamp = timestamp
+	canonical.BodyHash = req.Header.Get(c.BodyHashHeader)
+
+	mac := hmac.New(newHash, c.Secret)
+	mac.Write([]byte(c.Canonicalize(canonical)))
+	req.Header.Set(c.SignatureHeader, base64.StdEncoding.EncodeToString(mac.Sum(nil)))
+	return rt.rt.RoundTrip(req)
+}
+
+// readRequestBody 读取请求体并还原, 优先使用 GetBody 以免消耗原始请求体
+func readRequestBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		defer body.Close()
+		return ioutil.ReadAll(body)
+	}
+	data, err := ioutil.ReadAll(req.Body)
+	_ = req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	req.Body = ioutil.NopCloser(bytes.NewReader(data))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return ioutil.NopCloser(bytes.NewReader(data)), nil
+	}
+	return data, nil
+}
+
+func canonicalRequest(req *http.Request, signedHeaders []string) *CanonicalRequest {
+	c := &CanonicalRequest{
+		Method: req.Method,
+		Path:   req.URL.EscapedPath(),
+	}
+	if len(c.Path) == 0 {
+		c.Path = "/"
+	}
+
+	query := req.URL.Query()
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var pairs []string
+	for _, key := range keys {
+		values := append([]string(nil), query[key]...)
+		sort.Strings(values)
+		for _, value := range values {
+			pairs = append(pairs, escapeQuery(key)+"="+escapeQuery(value))
+		}
+	}
+	c.Query = strings.Join(pairs, "&")
+
+	for _, name := range signedHeaders {
+		c.SignedHeaders = append(c.SignedHeaders, strings.ToLower(name))
+	}
+	sort.Strings(c.SignedHeaders)
+	for _, name := range c.SignedHeaders {
+		var value string
+		if name == "host" {
+			value = req.Host
+			if len(value) == 0 {
+				value = req.URL.Host
+			}
+		} else {
+			values := req.Header.Values(name)
+			for i := range values {
+				values[i] = strings.TrimSpace(values[i])
+			}
+			value = strings.Join(values, ",")
+		}
+		c.Headers = append(c.Headers, name+":"+value)
+	}
+	return c
+}
+
+// escapeQuery 空格编码为 %20 而不是 +
+func escapeQuery(s string) string {
+	return strings.Replace(url.QueryEscape(s), "+", "%20", -1)
+}
+
+type hmacAuthProvider struct {
+	wrapper WrapperFunc
+}
+
+// NewHMACAuthProvider 支持的配置: key_id, secret, algorithm, signed_headers (逗号分隔)
+func NewHMACAuthProvider(config map[string]string) (AuthProvider, error) {
+	var signedHeaders []string
+	for _, name := range strings.Split(config["signed_headers"], ",") {
+		if name = strings.TrimSpace(name); len(name) > 0 {
+			signedHeaders = append(signedHeaders, name)
+		}
+	}
+	wrapper, err := NewHMACSigner(HMACConfig{
+		KeyID:         config["key_id"],
+		Secret:        []byte(config["secret"]),
+		Algorithm:     config["algorithm"],
+		SignedHeaders: signedHeaders,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &hmacAuthProvider{wrapper: wrapper}, nil
+}
+
+func (p *hmacAuthProvider) WrapTransport(rt http.RoundTripper) http.RoundTripper {
+	return p.wrapper(rt)
+}
+
+func (p *hmacAuthProvider) Login() error {
+	return nil
+}
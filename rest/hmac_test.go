@@ -0,0 +1,155 @@
+package rest
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// verifyHMAC 服务端按照相同规则校验签名
+func verifyHMAC(r *http.Request, secret []byte, signedHeaders []string) (string, bool) {
+	body, _ := ioutil.ReadAll(r.Body)
+	bodyHash := sha256.Sum256(body)
+	if r.Header.Get("X-Content-Hash") != hex.EncodeToString(bodyHash[:]) {
+		return string(body), false
+	}
+	canonical := canonicalRequest(r, signedHeaders)
+	canonical.Timestamp = r.Header.Get("X-Timestamp")
+	canonical.BodyHash = r.Header.Get("X-Content-Hash")
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(DefaultCanonicalize(canonical)))
+	expected := base64.StdEncoding.EncodeToString(mac.Sum(nil))
+	return string(body), hmac.Equal([]byte(expected), []byte(r.Header.Get("X-Signature")))
+}
+
+func TestCanonicalRequest(t *testing.T) {
+	req, _ := http.NewRequest("GET", "http://example.com/api/v1/users?b=2&a=3&a=1&name=hello world", nil)
+	req.Header.Set("X-Date", " today ")
+	c := canonicalRequest(req, []string{"X-Date", "Host"})
+	if c.Query != "a=1&a=3&b=2&name=hello%20world" {
+		t.Fatalf("unexpected query %q", c.Query)
+	}
+	if c.Path != "/api/v1/users" {
+		t.Fatalf("unexpected path %q", c.Path)
+	}
+	if len(c.Headers) != 2 || c.Headers[0] != "host:example.com" || c.Headers[1] != "x-date:today" {
+		t.Fatalf("unexpected headers %v", c.Headers)
+	}
+}
+
+func TestNewHMACSigner(t *testing.T) {
+	if _, err := NewHMACSigner(HMACConfig{}); err == nil {
+		t.Fatal("expected missing secret to be rejected")
+	}
+	if _, err := NewHMACSigner(HMACConfig{Secret: []byte("secret"), Algorithm: "HMAC-MD5"}); err == nil {
+		t.Fatal("expected unsupported algorithm to be rejected")
+	}
+
+	secret := []byte("secret")
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, ok := verifyHMAC(r, secret, []string{"Host", "Content-Type"})
+		if !ok || r.Header.Get("X-Key-Id") != "key" || r.Header.Get("X-Timestamp") != "1600000000" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		// 第一次返回 503, 重试时请求体需要保持不变
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte(body))
+	}))
+	defer server.Close()
+
+	signer, err := NewHMACSigner(HMACConfig{
+		KeyID:         "key",
+		Secret:        secret,
+		SignedHeaders: []string{"Host", "Content-Type"},
+		Now: func() time.Time {
+			return time.Unix(1600000000, 0)
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	client, err := NewRESTClientFor(&Config{
+		Host:          server.URL,
+		WrapTransport: signer,
+		MaxRetries:    1,
+		Backoff:       Backoff{Duration: time.Millisecond},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := client.Put().Path("/orders").Param("id", "1").Body([]byte(`{"amount":1}`)).DoRaw(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(body) != `{"amount":1}` || calls != 2 {
+		t.Fatalf("unexpected response %q after %d calls", body, calls)
+	}
+}
+
+func TestHMACRoundTripper_NonReplayableBody(t *testing.T) {
+	signer, _ := NewHMACSigner(HMACConfig{KeyID: "key", Secret: []byte("secret")})
+	record := &recordRoundTripper{}
+	req, _ := http.NewRequest("PUT", "http://localhost/", ioutil.NopCloser(&onceReader{data: []byte("payload")}))
+	if _, err := signer(record).RoundTrip(req); err != nil {
+		t.Fatal(err)
+	}
+	body, _ := ioutil.ReadAll(record.req.Body)
+	if string(body) != "payload" {
+		t.Fatalf("expected body to be restored after signing, got %q", body)
+	}
+	if record.req.GetBody == nil {
+		t.Fatal("expected GetBody to be set after reading the body")
+	}
+}
+
+type onceReader struct {
+	data []byte
+	read bool
+}
+
+func (r *onceReader) Read(p []byte) (int, error) {
+	if r.read {
+		return 0, io.EOF
+	}
+	r.read = true
+	return copy(p, r.data), nil
+}
+
+func TestAuthConfig_HMAC(t *testing.T) {
+	secret := []byte("secret")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := verifyHMAC(r, secret, []string{"host"}); !ok {
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer server.Close()
+
+	client, err := NewRESTClientFor(&Config{
+		Host: server.URL,
+		AuthConfig: AuthConfig{Name: "hmac", Config: map[string]string{
+			"key_id":         "key",
+			"secret":         "secret",
+			"signed_headers": "host",
+		}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Get().Path("/").DoRaw(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,95 @@
+package rest
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// RequestInfo 指标的标签, Route 为 Request.Route 返回的路径模板, 没有模板时为 UntemplatedRoute
+type RequestInfo struct {
+	Verb  string
+	Host  string
+	Route string
+}
+
+type requestInfoKey struct{}
+
+// RequestInfoFrom 返回 rest.Request 发起的请求的 RequestInfo, 可以在 WrapperFunc 中通过 req.Context() 获取
+func RequestInfoFrom(ctx context.Context) (RequestInfo, bool) {
+	info, ok := ctx.Value(requestInfoKey{}).(RequestInfo)
+	return info, ok
+}
+
+// Metrics 请求指标, 实现需要支持并发调用
+type Metrics interface {
+	// RequestLatency 一次 Do/DoRaw/Stream 的总耗时, 包括限流等待与重试
+	RequestLatency(ctx context.Context, info RequestInfo, latency time.Duration)
+	// RequestResult 每次 HTTP 请求的结果, 没有收到响应时 code 为 0
+	RequestResult(ctx context.Context, info RequestInfo, code int)
+	// RequestRetry 每次重试前调用
+	RequestRetry(ctx context.Context, info RequestInfo)
+	// RateLimiterLatency 在限流器上等待的时间
+	RateLimiterLatency(ctx context.Context, info RequestInfo, latency time.Duration)
+	// RequestSize 请求体发送的字节数
+	RequestSize(ctx context.Context, info RequestInfo, size int64)
+	// ResponseSize 响应体读取的字节数, 在响应体关闭时调用
+	ResponseSize(ctx context.Context, info RequestInfo, size int64)
+}
+
+// NoopMetrics 不记录任何指标, 可以嵌入到只关心部分指标的实现中
+type NoopMetrics struct{}
+
+var _ Metrics = NoopMetrics{}
+
+func (NoopMetrics) RequestLatency(context.Context, RequestInfo, time.Duration)     {}
+func (NoopMetrics) RequestResult(context.Context, RequestInfo, int)                {}
+func (NoopMetrics) RequestRetry(context.Context, RequestInfo)                      {}
+func (NoopMetrics) RateLimiterLatency(context.Context, RequestInfo, time.Duration) {}
+func (NoopMetrics) RequestSize(context.Context, RequestInfo, int64)                {}
+func (NoopMetrics) ResponseSize(context.Context, RequestInfo, int64)               {}
+
+// countingReadCloser 统计读取的字节数, 关闭时上报一次
+type countingReadCloser struct {
+	io.ReadCloser
+	n      int64
+	once   sync.Once
+	report func(n int64)
+}
+
+func (c *countingReadCloser) Read(p []byte) (int, error) {
+	n, err := c.ReadCloser.Read(p)
+	atomic.AddInt64(&c.n, int64(n))
+	return n, err
+}
+
+func (c *countingReadCloser) Close() error {
+	err := c.ReadCloser.Close()
+	c.once.Do(func() {
+		c.report(atomic.LoadInt64(&c.n))
+	})
+	return err
+}
+
+// countRequestBody 请求体在 Transport 关闭时上报, 重试时每次请求分别上报
+func countRequestBody(ctx context.Context, metrics Metrics, info RequestInfo, req *http.Request) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return
+	}
+	req.Body = &countingReadCloser{ReadCloser: req.Body, report: func(n int64) {
+		metrics.RequestSize(ctx, info, n)
+	}}
+}
+
+// countResponseBody 响应体在调用方关闭时上报
+func countResponseBody(ctx context.Context, metrics Metrics, info RequestInfo, resp *http.Response) {
+	if resp == nil || resp.Body == nil {
+		return
+	}
+	resp.Body = &countingReadCloser{ReadCloser: resp.Body, report: func(n int64) {
+		metrics.ResponseSize(ctx, info, n)
+	}}
+}
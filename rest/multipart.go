@@ -0,0 +1,116 @@
+package rest
+
+import (
+	"io"
+	"mime/multipart"
+	"sync"
+)
+
+// MultipartForm multipart/form-data 请求体, 文件内容在发送请求时才从 io.Reader 中流式读取
+type MultipartForm struct {
+	parts []func(w *multipart.Writer) error
+	// closers 尚未关闭的文件, 请求失败时由 close 统一关闭
+	closers []io.Closer
+}
+
+func NewMultipartForm() *MultipartForm {
+	return &MultipartForm{}
+}
+
+// Field 添加普通字段
+func (f *MultipartForm) Field(name, value string) *MultipartForm {
+	f.parts = append(f.parts, func(w *multipart.Writer) error {
+		return w.WriteField(name, value)
+	})
+	return f
+}
+
+// File 添加文件, reader 实现 io.Closer 时发送完成或请求失败后关闭
+func (f *MultipartForm) File(fieldName, fileName string, reader io.Reader) *MultipartForm {
+	i := -1
+	if closer, ok := reader.(io.Closer); ok {
+		i = len(f.closers)
+		f.closers = append(f.closers, closer)
+	}
+	f.parts = append(f.parts, func(w *multipart.Writer) error {
+		if i >= 0 {
+			defer f.closeAt(i)
+		}
+		part, err := w.CreateFormFile(fieldName, fileName)
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(part, reader)
+		return err
+	})
+	return f
+}
+
+func (f *MultipartForm) closeAt(i int) {
+	if f.closers[i] != nil {
+		_ = f.closers[i].Close()
+		f.closers[i] = nil
+	}
+}
+
+// close 关闭所有尚未关闭的文件
+func (f *MultipartForm) close() {
+	for i := range f.closers {
+		f.closeAt(i)
+	}
+}
+
+// multipartBody 第一次读取时开始写入, 避免请求未发送时泄漏 goroutine
+type multipartBody struct {
+	form *MultipartForm
+	w    *multipart.Writer
+	pr   *io.PipeReader
+	pw   *io.PipeWriter
+	once sync.Once
+}
+
+func newMultipartBody(form *MultipartForm) *multipartBody {
+	pr, pw := io.Pipe()
+	return &multipartBody{
+		form: form,
+		w:    multipart.NewWriter(pw),
+		pr:   pr,
+		pw:   pw,
+	}
+}
+
+func (b *multipartBody) ContentType() string {
+	return b.w.FormDataContentType()
+}
+
+func (b *multipartBody) Read(p []byte) (int, error) {
+	b.once.Do(func() {
+		go b.write()
+	})
+	return b.pr.Read(p)
+}
+
+// Close 关闭请求体, 尚未开始写入时直接关闭所有文件
+func (b *multipartBody) Close() error {
+	err := b.pr.Close()
+	started := true
+	b.once.Do(func() {
+		started = false
+	})
+	if !started {
+		b.form.close()
+	}
+	return err
+}
+
+func (b *multipartBody) write() {
+	// 写入失败时剩余的文件不会再被读取
+	defer b.form.close()
+	for _, part := range b.form.parts {
+		if err := part(b.w); err != nil {
+			_ = b.pw.CloseWithError(err)
+			return
+		}
+	}
+	_ = b.pw.CloseWithError(b.w.Close())
+}
@@ -0,0 +1,157 @@
+package rest
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRequest_FormData(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Type") != "application/x-www-form-urlencoded" {
+			w.WriteHeader(http.StatusUnsupportedMediaType)
+			return
+		}
+		_ = r.ParseForm()
+		_, _ = w.Write([]byte(r.PostForm.Encode()))
+	}))
+	defer server.Close()
+
+	client, _ := NewRESTClientFor(&Config{Host: server.URL})
+	cases := []struct {
+		obj      interface{}
+		expected string
+	}{
+		{url.Values{"name": {"hello world"}, "tag": {"a", "b"}}, "name=hello+world&tag=a&tag=b"},
+		{map[string]string{"name": "man"}, "name=man"},
+		{&User{Name: "hello", Age: 18}, "age=18&name=hello"},
+	}
+	for _, c := range cases {
+		body, err := client.Post().Path("/").FormData(c.obj).DoRaw(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(body) != c.expected {
+			t.Errorf("expected %q, got %q", c.expected, body)
+		}
+	}
+	if _, err := client.Post().Path("/").FormData("not a struct").DoRaw(context.Background()); err != ErrStruct {
+		t.Fatalf("expected ErrStruct, got %v", err)
+	}
+}
+
+// patternReader 按需生成数据, 不在内存中保存完整文件
+type patternReader struct {
+	remaining int64
+	closed    bool
+}
+
+func (p *patternReader) Read(b []byte) (int, error) {
+	if p.remaining <= 0 {
+		return 0, io.EOF
+	}
+	if int64(len(b)) > p.remaining {
+		b = b[:p.remaining]
+	}
+	for i := range b {
+		b[i] = 'x'
+	}
+	p.remaining -= int64(len(b))
+	return len(b), nil
+}
+
+func (p *patternReader) Close() error {
+	p.closed = true
+	return nil
+}
+
+func TestRequest_Multipart(t *testing.T) {
+	const size = 8 << 20
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		reader, err := r.MultipartReader()
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		var result []string
+		for {
+			part, err := reader.NextPart()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			n, _ := io.Copy(ioutil.Discard, part)
+			result = append(result, part.FormName()+":"+part.FileName()+":"+strconv.FormatInt(n, 10))
+		}
+		if r.URL.Query().Get("fail") == "true" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		_, _ = w.Write([]byte(strings.Join(result, ",")))
+	}))
+	defer server.Close()
+
+	client, _ := NewRESTClientFor(&Config{Host: server.URL, MaxRetries: 3, Backoff: Backoff{Duration: time.Millisecond}})
+	file := &patternReader{remaining: size}
+	form := NewMultipartForm().
+		Field("name", "export").
+		File("file", "export.csv", file)
+	body, err := client.Post().Path("/upload").Multipart(form).DoRaw(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(body) != "name::6,file:export.csv:"+strconv.Itoa(size) {
+		t.Fatalf("unexpected parts %q", body)
+	}
+	if !file.closed {
+		t.Fatal("expected file reader to be closed after upload")
+	}
+
+	// 流式请求体不能重试
+	atomic.StoreInt32(&calls, 0)
+	form = NewMultipartForm().File("file", "a.txt", strings.NewReader("a"))
+	_, err = client.Post().Path("/upload").Param("fail", "true").Multipart(form).DoRaw(context.Background())
+	if !IsServerError(err) || calls != 1 {
+		t.Fatalf("expected a single failed attempt, got %v after %d calls", err, calls)
+	}
+}
+
+func TestRequest_MultipartCloseOnError(t *testing.T) {
+	client, _ := NewRESTClientFor(&Config{Host: "http://127.0.0.1:1"})
+	cases := map[string]func(form *MultipartForm) *Request{
+		// 构造请求时已经出错
+		"build error": func(form *MultipartForm) *Request {
+			return client.Post().Name("").Multipart(form)
+		},
+		// 路径参数缺失, 请求不会发出
+		"missing path param": func(form *MultipartForm) *Request {
+			return client.Post().Path("/users/{id}").Multipart(form)
+		},
+		// 连接失败, 请求体没有被读取
+		"dial error": func(form *MultipartForm) *Request {
+			return client.Post().Path("/upload").Multipart(form)
+		},
+	}
+	for name, build := range cases {
+		file := &patternReader{remaining: 1 << 10}
+		form := NewMultipartForm().File("file", "a.txt", file)
+		if err := build(form).Do(context.Background()).Error(); err == nil {
+			t.Errorf("%s: expected request to fail", name)
+		}
+		if !file.closed {
+			t.Errorf("%s: expected file reader to be closed", name)
+		}
+	}
+}
@@ -0,0 +1,247 @@
+package rest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// tokenExpiryDelta token 过期前提前刷新的时间
+	tokenExpiryDelta = 10 * time.Second
+	// tokenRequestTimeout 获取 token 的超时时间, token 服务无响应时避免所有请求一直阻塞
+	tokenRequestTimeout = 30 * time.Second
+)
+
+type oauth2Token struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int64  `json:"expires_in"`
+
+	expiry time.Time
+}
+
+func (t *oauth2Token) valid(now time.Time) bool {
+	if t == nil || len(t.AccessToken) == 0 {
+		return false
+	}
+	return t.expiry.IsZero() || now.Add(tokenExpiryDelta).Before(t.expiry)
+}
+
+func (t *oauth2Token) header() string {
+	tokenType := t.TokenType
+	// 部分服务端返回小写的 bearer
+	if len(tokenType) == 0 || strings.EqualFold(tokenType, "bearer") {
+		tokenType = "Bearer"
+	}
+	return tokenType + " " + t.AccessToken
+}
+
+// tokenCall 正在进行的 token 请求, 并发的刷新共用同一个请求
+type tokenCall struct {
+	done  chan struct{}
+	token *oauth2Token
+	err   error
+}
+
+// oauth2AuthProvider OAuth2 client credentials 模式
+type oauth2AuthProvider struct {
+	tokenURL     string
+	clientID     string
+	clientSecret string `datapolicy:"password"`
+	scopes       []string
+	// authInParams client_id/client_secret 放在表单中而不是 Basic 认证
+	authInParams bool
+
+	mu sync.Mutex
+	// client 默认使用 http.DefaultTransport, 用于客户端时使用客户端的 Transport
+	client   *http.Client
+	token    *oauth2Token
+	inflight *tokenCall
+}
+
+var _ AuthProvider = &oauth2AuthProvider{}
+
+// NewOAuth2AuthProvider 根据 AuthConfig.Config 构造 OAuth2 client credentials 认证
+// 支持的配置: token_url, client_id, client_secret, scopes (空格或逗号分隔), auth_style (header 或 params)
+func NewOAuth2AuthProvider(config map[string]string) (AuthProvider, error) {
+	p := &oauth2AuthProvider{
+		tokenURL:     config["token_url"],
+		clientID:     config["client_id"],
+		clientSecret: config["client_secret"],
+		authInParams: config["auth_style"] == "params",
+		client:       &http.Client{Timeout: tokenRequestTimeout},
+	}
+	if len(p.tokenURL) == 0 {
+		return nil, errors.New("oauth2: token_url is required")
+	}
+	if len(p.clientID) == 0 {
+		return nil, errors.New("oauth2: client_id is required")
+	}
+	p.scopes = strings.FieldsFunc(config["scopes"], func(r rune) bool {
+		return r == ' ' || r == ','
+	})
+	return p, nil
+}
+
+func (p *oauth2AuthProvider) WrapTransport(rt http.RoundTripper) http.RoundTripper {
+	return &oauth2RoundTripper{provider: p, rt: rt}
+}
+
+// setTransport 获取 token 使用客户端的 Transport, 不经过 WrapTransport 等包装
+func (p *oauth2AuthProvider) setTransport(rt http.RoundTripper) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.client = &http.Client{Transport: rt, Timeout: tokenRequestTimeout}
+}
+
+// Login 获取新的 token
+func (p *oauth2AuthProvider) Login() error {
+	p.invalidate(nil)
+	_, err := p.Token(context.Background())
+	return err
+}
+
+// Token 返回缓存的 token, 即将过期时刷新
+func (p *oauth2AuthProvider) Token(ctx context.Context) (*oauth2Token, error) {
+	p.mu.Lock()
+	if p.token.valid(time.Now()) {
+		token := p.token
+		p.mu.Unlock()
+		return token, nil
+	}
+	call := p.inflight
+	if call == nil {
+		// 刷新不跟随单个请求的 ctx 取消, 其他等待者仍然需要结果, 由 client 的超时时间限制
+		call = &tokenCall{done: make(chan struct{})}
+		p.inflight = call
+		go p.refresh(p.client, call)
+	}
+	p.mu.Unlock()
+
+	select {
+	case <-call.done:
+		return call.token, call.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (p *oauth2AuthProvider) refresh(client *http.Client, call *tokenCall) {
+	call.token, call.err = p.fetch(context.Background(), client)
+
+	p.mu.Lock()
+	if call.err == nil {
+		p.token = call.token
+	}
+	p.inflight = nil
+	p.mu.Unlock()
+	close(call.done)
+}
+
+// invalidate 丢弃 token, token 不为空时仅在缓存仍为该 token 时丢弃, 避免重复刷新
+func (p *oauth2AuthProvider) invalidate(token *oauth2Token) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if token == nil || p.token == token {
+		p.token = nil
+	}
+}
+
+func (p *oauth2AuthProvider) fetch(ctx context.Context, client *http.Client) (*oauth2Token, error) {
+	form := url.Values{}
+	form.Set("grant_type", "client_credentials")
+	if len(p.scopes) > 0 {
+		form.Set("scope", strings.Join(p.scopes, " "))
+	}
+	if p.authInParams {
+		form.Set("client_id", p.clientID)
+		form.Set("client_secret", p.clientSecret)
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", p.tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if !p.authInParams {
+		req.SetBasicAuth(url.QueryEscape(p.clientID), url.QueryEscape(p.clientSecret))
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("oauth2: cannot fetch token: %w", err)
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("oauth2: cannot fetch token: %w", err)
+	}
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return nil, fmt.Errorf("oauth2: cannot fetch token: %w", newStatusError(req, resp.StatusCode, body, nil))
+	}
+	token := &oauth2Token{}
+	if err := json.Unmarshal(body, token); err != nil {
+		return nil, fmt.Errorf("oauth2: cannot parse token response: %w", err)
+	}
+	if len(token.AccessToken) == 0 {
+		return nil, errors.New("oauth2: server response missing access_token")
+	}
+	if token.ExpiresIn > 0 {
+		token.expiry = time.Now().Add(time.Duration(token.ExpiresIn) * time.Second)
+	}
+	return token, nil
+}
+
+type oauth2RoundTripper struct {
+	provider *oauth2AuthProvider
+	rt       http.RoundTripper
+}
+
+func (rt *oauth2RoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	if len(req.Header.Get("Authorization")) != 0 {
+		return rt.rt.RoundTrip(req)
+	}
+	token, err := rt.provider.Token(req.Context())
+	if err != nil {
+		return nil, err
+	}
+	resp, err := rt.roundTrip(req, token)
+	if err != nil || resp.StatusCode != http.StatusUnauthorized {
+		return resp, err
+	}
+	// 401 时重新登录并重放一次, 请求体无法重放时直接返回
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return resp, nil
+	}
+	rt.provider.invalidate(token)
+	token, err = rt.provider.Token(req.Context())
+	if err != nil {
+		return resp, nil
+	}
+	readAndCloseResponseBody(resp)
+
+	retry := req
+	if req.GetBody != nil && req.Body != nil && req.Body != http.NoBody {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		retry = req.Clone(req.Context())
+		retry.Body = body
+	}
+	return rt.roundTrip(retry, token)
+}
+
+func (rt *oauth2RoundTripper) roundTrip(req *http.Request, token *oauth2Token) (*http.Response, error) {
+	req = CloneRequest(req)
+	req.Header.Set("Authorization", token.header())
+	return rt.rt.RoundTrip(req)
+}
@@ -0,0 +1,252 @@
+package rest
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type oauth2TestServer struct {
+	*httptest.Server
+	tokens    int32
+	expiresIn int
+	// revoked 之前签发的 token 返回 401
+	revoked int32
+}
+
+func newOAuth2TestServer(t *testing.T, expiresIn int) *oauth2TestServer {
+	s := &oauth2TestServer{expiresIn: expiresIn}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/token":
+			user, pass, ok := r.BasicAuth()
+			if !ok || user != "client" || pass != "secret" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			_ = r.ParseForm()
+			if r.PostForm.Get("grant_type") != "client_credentials" || r.PostForm.Get("scope") != "read write" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			// 模拟较慢的 token 服务, 便于测试并发刷新
+			time.Sleep(10 * time.Millisecond)
+			n := atomic.AddInt32(&s.tokens, 1)
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = fmt.Fprintf(w, `{"access_token":"token-%d","token_type":"bearer","expires_in":%d}`, n, s.expiresIn)
+		default:
+			var n int32
+			if _, err := fmt.Sscanf(r.Header.Get("Authorization"), "Bearer token-%d", &n); err != nil || n <= atomic.LoadInt32(&s.revoked) {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			body, _ := ioutil.ReadAll(r.Body)
+			_, _ = w.Write(body)
+		}
+	}))
+	return s
+}
+
+func newOAuth2TestProvider(t *testing.T, server *oauth2TestServer) *oauth2AuthProvider {
+	provider, err := NewOAuth2AuthProvider(map[string]string{
+		"token_url":     server.URL + "/token",
+		"client_id":     "client",
+		"client_secret": "secret",
+		"scopes":        "read,write",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return provider.(*oauth2AuthProvider)
+}
+
+func TestNewOAuth2AuthProvider_Validation(t *testing.T) {
+	if _, err := NewOAuth2AuthProvider(map[string]string{"client_id": "client"}); err == nil {
+		t.Fatal("expected missing token_url to be rejected")
+	}
+	if _, err := NewOAuth2AuthProvider(map[string]string{"token_url": "http://localhost"}); err == nil {
+		t.Fatal("expected missing client_id to be rejected")
+	}
+}
+
+func TestOAuth2AuthProvider_Cache(t *testing.T) {
+	server := newOAuth2TestServer(t, 3600)
+	defer server.Close()
+	provider := newOAuth2TestProvider(t, server)
+	if err := provider.Login(); err != nil {
+		t.Fatal(err)
+	}
+
+	client, _ := NewRESTClientFor(&Config{Host: server.URL, AuthProvider: provider})
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := client.Get().Path("/").DoRaw(context.Background()); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if server.tokens != 1 {
+		t.Fatalf("expected cached token to be reused, fetched %d tokens", server.tokens)
+	}
+}
+
+func TestOAuth2AuthProvider_Refresh(t *testing.T) {
+	// 过期时间小于提前刷新的时间, 每次都需要刷新
+	server := newOAuth2TestServer(t, 1)
+	defer server.Close()
+	provider := newOAuth2TestProvider(t, server)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := provider.Token(context.Background()); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if server.tokens != 1 {
+		t.Fatalf("expected concurrent refreshes to be coalesced, fetched %d tokens", server.tokens)
+	}
+	if _, err := provider.Token(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if server.tokens != 2 {
+		t.Fatalf("expected token close to expiry to be refreshed, fetched %d tokens", server.tokens)
+	}
+}
+
+func TestOAuth2AuthProvider_Unauthorized(t *testing.T) {
+	server := newOAuth2TestServer(t, 3600)
+	defer server.Close()
+	provider := newOAuth2TestProvider(t, server)
+	client, _ := NewRESTClientFor(&Config{Host: server.URL, AuthProvider: provider})
+
+	if _, err := client.Post().Path("/").Body([]byte("first")).DoRaw(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	// 服务端吊销 token 后重新登录并重放请求
+	atomic.StoreInt32(&server.revoked, 1)
+	body, err := client.Post().Path("/").Body([]byte("replay")).DoRaw(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(body) != "replay" || server.tokens != 2 {
+		t.Fatalf("expected request to be replayed with a new token, got %q after %d tokens", body, server.tokens)
+	}
+
+	// 新 token 仍然 401 时只重放一次
+	atomic.StoreInt32(&server.revoked, 100)
+	if _, err := client.Get().Path("/").DoRaw(context.Background()); !IsUnauthorized(err) {
+		t.Fatalf("expected unauthorized error, got %v", err)
+	}
+	if server.tokens != 3 {
+		t.Fatalf("expected a single re-login, fetched %d tokens", server.tokens)
+	}
+}
+
+func TestOAuth2AuthProvider_LoginError(t *testing.T) {
+	server := newOAuth2TestServer(t, 3600)
+	defer server.Close()
+	provider, _ := NewOAuth2AuthProvider(map[string]string{
+		"token_url":     server.URL + "/token",
+		"client_id":     "client",
+		"client_secret": "wrong",
+	})
+	if err := provider.Login(); !IsUnauthorized(err) {
+		t.Fatalf("expected unauthorized login error, got %v", err)
+	}
+}
+
+func TestNullAuthProvider(t *testing.T) {
+	record := &recordRoundTripper{}
+	rt := NewNullAuthProvider().WrapTransport(record)
+	req, _ := http.NewRequest("GET", "http://localhost", nil)
+	if _, err := rt.RoundTrip(req); err != nil || record.req != req {
+		t.Fatal("expected null auth provider to pass requests through")
+	}
+}
+
+func TestOAuth2AuthProvider_HangingTokenServer(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer server.Close()
+	defer close(release)
+
+	provider, _ := NewOAuth2AuthProvider(map[string]string{"token_url": server.URL, "client_id": "client"})
+	p := provider.(*oauth2AuthProvider)
+	p.client.Timeout = 100 * time.Millisecond
+
+	// 发起刷新的请求同样跟随自己的 ctx 返回
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := p.Token(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("expected the leader to return with its ctx, got %v", err)
+	}
+	// 刷新受 client 的超时时间限制
+	start := time.Now()
+	if _, err := p.Token(context.Background()); err == nil {
+		t.Fatal("expected token request to time out")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("expected bounded token request, took %v", elapsed)
+	}
+}
+
+func TestOAuth2AuthProvider_ClientTransport(t *testing.T) {
+	server := newOAuth2TestServer(t, 3600)
+	defer server.Close()
+
+	var paths []string
+	var mu sync.Mutex
+	transport := roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		mu.Lock()
+		paths = append(paths, req.URL.Path)
+		mu.Unlock()
+		return http.DefaultTransport.RoundTrip(req)
+	})
+	client, err := NewRESTClientFor(&Config{
+		Host:      server.URL,
+		Transport: transport,
+		AuthConfig: AuthConfig{Name: "oauth2", Config: map[string]string{
+			"token_url":     server.URL + "/token",
+			"client_id":     "client",
+			"client_secret": "secret",
+			"scopes":        "read write",
+		}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Get().Path("/").DoRaw(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(paths) != 2 || paths[0] != "/token" {
+		t.Fatalf("expected token request to use the configured transport, got %v", paths)
+	}
+}
+
+type roundTripperFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
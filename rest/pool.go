@@ -1,11 +1,13 @@
 package rest
 
 import (
+	"fmt"
 	"net/http"
 	"sync"
 )
 
 type TransportCacheInterfce interface {
+	// Get 获取 config 对应的 Transport, 相同配置共享连接池, TLS 配置错误时返回 nil
 	Get(config *TransportConfig) *http.Transport
 }
 
@@ -14,7 +16,18 @@ var TransportCache = newTransportPool()
 type transportCacheKey string
 
 func (t transportCacheKey) String() string {
-	return ""
+	return string(t)
+}
+
+// cacheKey 自定义 Dial 与 Proxy 为函数, 无法比较, 不进行缓存
+// 使用填充默认值之后的配置, 零值与显式的默认值共享同一个 Transport
+func cacheKey(config *TransportConfig) (transportCacheKey, bool) {
+	if config.Dial != nil || config.Proxy != nil {
+		return "", false
+	}
+	dialTimeout, idleConnTimeout := transportTimeouts(config)
+	return transportCacheKey(fmt.Sprintf("dialTimeout:%d,idleConnTimeout:%d,tls:%s",
+		dialTimeout, idleConnTimeout, config.TLS.cacheKey())), true
 }
 
 type transportPool struct {
@@ -23,7 +36,79 @@ type transportPool struct {
 }
 
 func (t *transportPool) Get(config *TransportConfig) *http.Transport {
-	return nil
+	transport, err := t.GetE(config)
+	if err != nil {
+		return nil
+	}
+	return transport
+}
+
+// GetE 与 Get 相同, 返回 TLS 配置错误
+func (t *transportPool) GetE(config *TransportConfig) (*http.Transport, error) {
+	key, ok := cacheKey(config)
+	if !ok {
+		return newHTTPTransport(config)
+	}
+
+	t.RLock()
+	transport, ok := t.pool[key]
+	t.RUnlock()
+	if ok {
+		return transport, nil
+	}
+
+	t.Lock()
+	defer t.Unlock()
+	if transport, ok := t.pool[key]; ok {
+		return transport, nil
+	}
+	transport, err := newHTTPTransport(config)
+	if err != nil {
+		return nil, err
+	}
+	t.pool[key] = transport
+	return transport, nil
+}
+
+// Delete 移除 config 对应的 Transport 并关闭空闲连接
+func (t *transportPool) Delete(config *TransportConfig) {
+	key, ok := cacheKey(config)
+	if !ok {
+		return
+	}
+	t.Lock()
+	transport, ok := t.pool[key]
+	delete(t.pool, key)
+	t.Unlock()
+	if ok {
+		transport.CloseIdleConnections()
+	}
+}
+
+// Clear 移除所有 Transport 并关闭空闲连接
+func (t *transportPool) Clear() {
+	t.Lock()
+	pool := t.pool
+	t.pool = make(map[transportCacheKey]*http.Transport)
+	t.Unlock()
+	for _, transport := range pool {
+		transport.CloseIdleConnections()
+	}
+}
+
+// CloseIdleConnections 关闭所有 Transport 的空闲连接
+func (t *transportPool) CloseIdleConnections() {
+	t.RLock()
+	defer t.RUnlock()
+	for _, transport := range t.pool {
+		transport.CloseIdleConnections()
+	}
+}
+
+func (t *transportPool) Len() int {
+	t.RLock()
+	defer t.RUnlock()
+	return len(t.pool)
 }
 
 var _ TransportCacheInterfce = &transportPool{}
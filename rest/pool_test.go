@@ -0,0 +1,85 @@
+package rest
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestTransportPool_Get(t *testing.T) {
+	pool := newTransportPool()
+
+	get := func(config *TransportConfig) *http.Transport {
+		transport, err := pool.GetE(config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return transport
+	}
+	a := get(&TransportConfig{})
+	b := get(&TransportConfig{UserAgent: "agent", Username: "user"})
+	if a != b {
+		t.Fatal("expected equivalent configs to share a transport")
+	}
+	if get(&TransportConfig{DialTimeout: defaultDialTimeout, IdleConnTimeout: defaultIdleConnTimeout}) != a {
+		t.Fatal("expected explicit defaults to share a transport with the zero value")
+	}
+	c := get(&TransportConfig{DialTimeout: time.Second})
+	if a == c {
+		t.Fatal("expected different timeouts to use different transports")
+	}
+	if pool.Len() != 2 {
+		t.Fatalf("expected 2 cached transports, got %d", pool.Len())
+	}
+
+	dial := func(ctx context.Context, network, address string) (net.Conn, error) {
+		return nil, nil
+	}
+	proxy := func(*http.Request) (*url.URL, error) {
+		return nil, nil
+	}
+	if get(&TransportConfig{Dial: dial}) == get(&TransportConfig{Dial: dial}) {
+		t.Fatal("expected configs with custom Dial not to be cached")
+	}
+	if get(&TransportConfig{Proxy: proxy}) == get(&TransportConfig{Proxy: proxy}) {
+		t.Fatal("expected configs with custom Proxy not to be cached")
+	}
+	if pool.Len() != 2 {
+		t.Fatalf("expected uncacheable configs not to be stored, got %d", pool.Len())
+	}
+
+	pool.Delete(&TransportConfig{DialTimeout: time.Second})
+	if pool.Len() != 1 {
+		t.Fatalf("expected 1 cached transport after delete, got %d", pool.Len())
+	}
+	pool.CloseIdleConnections()
+	pool.Clear()
+	if pool.Len() != 0 {
+		t.Fatalf("expected empty cache after clear, got %d", pool.Len())
+	}
+	if get(&TransportConfig{}) == a {
+		t.Fatal("expected a new transport after clear")
+	}
+}
+
+func TestNewRESTClientFor_SharedTransport(t *testing.T) {
+	transport := func(config *Config) http.RoundTripper {
+		client, err := NewRESTClientFor(config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return client.(*Client).Client.Transport
+	}
+	a := transport(&Config{Host: "http://localhost:8080"})
+	b := transport(&Config{Host: "http://localhost:8081"})
+	if a != b {
+		t.Fatal("expected clients with the same transport config to share a transport")
+	}
+	custom := &http.Transport{}
+	if transport(&Config{Host: "http://localhost:8080", Transport: custom}) != custom {
+		t.Fatal("expected custom transport to be used as is")
+	}
+}
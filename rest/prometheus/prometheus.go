@@ -0,0 +1,148 @@
+package prometheus
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/ultraman/go-common/rest"
+)
+
+// Options 指标名称为 Namespace_Subsystem_xxx, Subsystem 默认为 rest_client
+type Options struct {
+	Namespace string
+	Subsystem string
+	// LatencyBuckets 请求耗时与限流等待时间的分桶, 默认为 prometheus.DefBuckets
+	LatencyBuckets []float64
+	// SizeBuckets 请求体与响应体大小的分桶, 默认为 64B 到 16MB
+	SizeBuckets []float64
+	// ConstLabels 附加在所有指标上的标签, 例如上游服务名
+	ConstLabels prometheus.Labels
+}
+
+// Metrics rest.Metrics 的 Prometheus 实现
+type Metrics struct {
+	requestLatency     *prometheus.HistogramVec
+	requestResult      *prometheus.CounterVec
+	requestRetry       *prometheus.CounterVec
+	rateLimiterLatency *prometheus.HistogramVec
+	requestSize        *prometheus.HistogramVec
+	responseSize       *prometheus.HistogramVec
+	requestHedge       *prometheus.CounterVec
+}
+
+var (
+	_ rest.Metrics      = &Metrics{}
+	_ rest.HedgeMetrics = &Metrics{}
+)
+
+// NewMetrics 创建指标并注册到 registerer, 同一个 registerer 中的多个客户端需要使用不同的 ConstLabels
+func NewMetrics(registerer prometheus.Registerer, opts Options) (*Metrics, error) {
+	if len(opts.Subsystem) == 0 {
+		opts.Subsystem = "rest_client"
+	}
+	if len(opts.LatencyBuckets) == 0 {
+		opts.LatencyBuckets = prometheus.DefBuckets
+	}
+	if len(opts.SizeBuckets) == 0 {
+		opts.SizeBuckets = prometheus.ExponentialBuckets(64, 4, 10)
+	}
+	labels := []string{"verb", "host", "route"}
+	m := &Metrics{
+		requestLatency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace:   opts.Namespace,
+			Subsystem:   opts.Subsystem,
+			Name:        "request_duration_seconds",
+			Help:        "Request latency in seconds, including rate limiting and retries.",
+			Buckets:     opts.LatencyBuckets,
+			ConstLabels: opts.ConstLabels,
+		}, labels),
+		requestResult: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace:   opts.Namespace,
+			Subsystem:   opts.Subsystem,
+			Name:        "requests_total",
+			Help:        "Number of HTTP requests, partitioned by status code.",
+			ConstLabels: opts.ConstLabels,
+		}, append(labels, "code")),
+		requestRetry: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace:   opts.Namespace,
+			Subsystem:   opts.Subsystem,
+			Name:        "request_retries_total",
+			Help:        "Number of request retries.",
+			ConstLabels: opts.ConstLabels,
+		}, labels),
+		rateLimiterLatency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace:   opts.Namespace,
+			Subsystem:   opts.Subsystem,
+			Name:        "rate_limiter_duration_seconds",
+			Help:        "Time spent waiting on the client side rate limiter.",
+			Buckets:     opts.LatencyBuckets,
+			ConstLabels: opts.ConstLabels,
+		}, labels),
+		requestSize: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace:   opts.Namespace,
+			Subsystem:   opts.Subsystem,
+			Name:        "request_size_bytes",
+			Help:        "Request body size in bytes.",
+			Buckets:     opts.SizeBuckets,
+			ConstLabels: opts.ConstLabels,
+		}, labels),
+		responseSize: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace:   opts.Namespace,
+			Subsystem:   opts.Subsystem,
+			Name:        "response_size_bytes",
+			Help:        "Response body size in bytes.",
+			Buckets:     opts.SizeBuckets,
+			ConstLabels: opts.ConstLabels,
+		}, labels),
+		requestHedge: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace:   opts.Namespace,
+			Subsystem:   opts.Subsystem,
+			Name:        "request_hedges_total",
+			Help:        "Number of hedged requests, partitioned by whether the hedge won.",
+			ConstLabels: opts.ConstLabels,
+		}, append(labels, "won")),
+	}
+	for _, collector := range []prometheus.Collector{
+		m.requestLatency, m.requestResult, m.requestRetry, m.rateLimiterLatency, m.requestSize, m.responseSize, m.requestHedge,
+	} {
+		if err := registerer.Register(collector); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+func (m *Metrics) RequestLatency(ctx context.Context, info rest.RequestInfo, latency time.Duration) {
+	m.requestLatency.WithLabelValues(info.Verb, info.Host, info.Route).Observe(latency.Seconds())
+}
+
+// RequestResult 没有收到响应时 code 标签为 <error>
+func (m *Metrics) RequestResult(ctx context.Context, info rest.RequestInfo, code int) {
+	label := "<error>"
+	if code > 0 {
+		label = strconv.Itoa(code)
+	}
+	m.requestResult.WithLabelValues(info.Verb, info.Host, info.Route, label).Inc()
+}
+
+func (m *Metrics) RequestRetry(ctx context.Context, info rest.RequestInfo) {
+	m.requestRetry.WithLabelValues(info.Verb, info.Host, info.Route).Inc()
+}
+
+func (m *Metrics) RateLimiterLatency(ctx context.Context, info rest.RequestInfo, latency time.Duration) {
+	m.rateLimiterLatency.WithLabelValues(info.Verb, info.Host, info.Route).Observe(latency.Seconds())
+}
+
+func (m *Metrics) RequestSize(ctx context.Context, info rest.RequestInfo, size int64) {
+	m.requestSize.WithLabelValues(info.Verb, info.Host, info.Route).Observe(float64(size))
+}
+
+func (m *Metrics) ResponseSize(ctx context.Context, info rest.RequestInfo, size int64) {
+	m.responseSize.WithLabelValues(info.Verb, info.Host, info.Route).Observe(float64(size))
+}
+
+func (m *Metrics) RequestHedge(ctx context.Context, info rest.RequestInfo, won bool) {
+	m.requestHedge.WithLabelValues(info.Verb, info.Host, info.Route, strconv.FormatBool(won)).Inc()
+}
@@ -0,0 +1,117 @@
+package prometheus
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/ultraman/go-common/rest"
+)
+
+func TestMetrics(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte(`{"id":"1"}`))
+	}))
+	defer server.Close()
+
+	registry := prometheus.NewRegistry()
+	metrics, err := NewMetrics(registry, Options{Namespace: "test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	client, err := rest.NewRESTClientFor(&rest.Config{
+		Host:       server.URL,
+		QPS:        1000,
+		MaxRetries: 1,
+		Backoff:    rest.Backoff{Duration: time.Millisecond},
+		Metrics:    metrics,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = client.Put().Path("/users/{id}").PathParam("id", "1").Body([]byte(`{"name":"a"}`)).Do(context.Background()).Error()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	info := []string{"PUT", server.Listener.Addr().String(), "/users/{id}"}
+	if got := testutil.ToFloat64(metrics.requestResult.WithLabelValues(append(info, "503")...)); got != 1 {
+		t.Errorf("expected one 503 result, got %v", got)
+	}
+	if got := testutil.ToFloat64(metrics.requestResult.WithLabelValues(append(info, "200")...)); got != 1 {
+		t.Errorf("expected one 200 result, got %v", got)
+	}
+	if got := testutil.ToFloat64(metrics.requestRetry.WithLabelValues(info...)); got != 1 {
+		t.Errorf("expected one retry, got %v", got)
+	}
+
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	counts := map[string]uint64{}
+	for _, family := range families {
+		for _, metric := range family.GetMetric() {
+			if h := metric.GetHistogram(); h != nil {
+				counts[family.GetName()] += h.GetSampleCount()
+			}
+		}
+	}
+	expected := map[string]uint64{
+		"test_rest_client_request_duration_seconds":      1,
+		"test_rest_client_rate_limiter_duration_seconds": 2,
+		"test_rest_client_request_size_bytes":            2,
+		"test_rest_client_response_size_bytes":           2,
+	}
+	for name, count := range expected {
+		if counts[name] != count {
+			t.Errorf("expected %d samples for %s, got %d", count, name, counts[name])
+		}
+	}
+
+	if _, err := NewMetrics(registry, Options{Namespace: "test"}); err == nil {
+		t.Error("expected duplicate registration error")
+	}
+}
+
+func TestMetrics_RequestHedge(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			select {
+			case <-r.Context().Done():
+				return
+			case <-time.After(2 * time.Second):
+			}
+		}
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	metrics, err := NewMetrics(prometheus.NewRegistry(), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	client, err := rest.NewRESTClientFor(&rest.Config{Host: server.URL, Metrics: metrics})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = client.Get().Path("/").Hedge(rest.HedgePolicy{Delay: 20 * time.Millisecond}).Do(context.Background()).Error()
+	if err != nil {
+		t.Fatal(err)
+	}
+	info := []string{"GET", server.Listener.Addr().String(), rest.UntemplatedRoute}
+	if got := testutil.ToFloat64(metrics.requestHedge.WithLabelValues(append(info, "true")...)); got != 1 {
+		t.Errorf("expected one winning hedge, got %v", got)
+	}
+}
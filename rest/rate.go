@@ -1,6 +1,11 @@
 package rest
 
-import "context"
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
 
 // RateLimiter 限流接口
 type RateLimiter interface {
@@ -11,16 +16,127 @@ type RateLimiter interface {
 	Wait(ctx context.Context) error
 }
 
+// NewRateLimiter 返回不做任何限制的 RateLimiter, 限流使用 NewTokenBucketRateLimiter
 func NewRateLimiter() RateLimiter {
-	return nil
+	return noopRateLimiter{}
 }
 
-// WithRetry 重试接口
-type WithRetry interface {
-	MaxRetries(max int)
-	Retry()
+// NewTokenBucketRateLimiter 令牌桶限流, qps 为每秒生成的令牌数, burst 为桶容量
+// qps <= 0 表示不限流, 返回不做任何限制的 RateLimiter
+func NewTokenBucketRateLimiter(qps float32, burst int) RateLimiter {
+	if qps <= 0 {
+		return noopRateLimiter{}
+	}
+	return newTokenBucketRateLimiter(qps, burst, time.Now)
 }
 
-func NewWithRetry(maxRetries int) WithRetry {
-	return nil
+// noopRateLimiter 不限流
+type noopRateLimiter struct{}
+
+var _ RateLimiter = noopRateLimiter{}
+
+func (noopRateLimiter) TryAccept() bool                { return true }
+func (noopRateLimiter) Accept()                        {}
+func (noopRateLimiter) Stop()                          {}
+func (noopRateLimiter) QPS() float32                   { return 0 }
+func (noopRateLimiter) Wait(ctx context.Context) error { return nil }
+
+type tokenBucketRateLimiter struct {
+	mu     sync.Mutex
+	qps    float32
+	burst  int
+	tokens float64
+	last   time.Time
+	now    func() time.Time
+}
+
+var _ RateLimiter = &tokenBucketRateLimiter{}
+
+func newTokenBucketRateLimiter(qps float32, burst int, now func() time.Time) *tokenBucketRateLimiter {
+	if burst <= 0 {
+		burst = 1
+	}
+	return &tokenBucketRateLimiter{
+		qps:    qps,
+		burst:  burst,
+		tokens: float64(burst),
+		last:   now(),
+		now:    now,
+	}
 }
+
+// advance 按照流逝的时间补充令牌, 调用方需持有锁
+func (t *tokenBucketRateLimiter) advance(now time.Time) {
+	elapsed := now.Sub(t.last)
+	if elapsed <= 0 {
+		return
+	}
+	t.last = now
+	t.tokens += elapsed.Seconds() * float64(t.qps)
+	if t.tokens > float64(t.burst) {
+		t.tokens = float64(t.burst)
+	}
+}
+
+// reserve 预占一个令牌, 返回需要等待的时间
+func (t *tokenBucketRateLimiter) reserve() time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.advance(t.now())
+	t.tokens--
+	if t.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-t.tokens / float64(t.qps) * float64(time.Second))
+}
+
+// cancel 归还预占的令牌
+func (t *tokenBucketRateLimiter) cancel() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.tokens++
+}
+
+func (t *tokenBucketRateLimiter) TryAccept() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.advance(t.now())
+	if t.tokens < 1 {
+		return false
+	}
+	t.tokens--
+	return true
+}
+
+func (t *tokenBucketRateLimiter) Accept() {
+	if wait := t.reserve(); wait > 0 {
+		time.Sleep(wait)
+	}
+}
+
+func (t *tokenBucketRateLimiter) Wait(ctx context.Context) error {
+	wait := t.reserve()
+	if wait <= 0 {
+		return nil
+	}
+	// 等待时间超过 ctx 的截止时间时直接返回, 避免无意义的等待
+	if deadline, ok := ctx.Deadline(); ok && t.now().Add(wait).After(deadline) {
+		t.cancel()
+		return fmt.Errorf("rate: wait(%v) would exceed context deadline: %w", wait, context.DeadlineExceeded)
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		t.cancel()
+		return ctx.Err()
+	}
+}
+
+func (t *tokenBucketRateLimiter) QPS() float32 {
+	return t.qps
+}
+
+func (t *tokenBucketRateLimiter) Stop() {}
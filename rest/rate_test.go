@@ -0,0 +1,154 @@
+package rest
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type fakeClock struct {
+	now time.Time
+}
+
+func (f *fakeClock) Now() time.Time {
+	return f.now
+}
+
+func (f *fakeClock) Step(d time.Duration) {
+	f.now = f.now.Add(d)
+}
+
+func TestTokenBucketRateLimiter_TryAccept(t *testing.T) {
+	clock := &fakeClock{now: time.Now()}
+	limiter := newTokenBucketRateLimiter(2, 3, clock.Now)
+	for i := 0; i < 3; i++ {
+		if !limiter.TryAccept() {
+			t.Fatalf("expected token %d to be accepted within burst", i)
+		}
+	}
+	if limiter.TryAccept() {
+		t.Fatal("expected bucket to be empty")
+	}
+	// 2 qps, 500ms 补充一个令牌
+	clock.Step(500 * time.Millisecond)
+	if !limiter.TryAccept() {
+		t.Fatal("expected token to be refilled")
+	}
+	if limiter.TryAccept() {
+		t.Fatal("expected bucket to be empty after refill")
+	}
+	// 长时间空闲不会超过桶容量
+	clock.Step(time.Hour)
+	accepted := 0
+	for limiter.TryAccept() {
+		accepted++
+	}
+	if accepted != 3 {
+		t.Fatalf("expected %d tokens after idle, got %d", 3, accepted)
+	}
+}
+
+func TestTokenBucketRateLimiter_Wait(t *testing.T) {
+	limiter := NewTokenBucketRateLimiter(100, 1)
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		if err := limiter.Wait(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// 第一个令牌立即获取, 之后每个 10ms
+	if elapsed := time.Since(start); elapsed < 35*time.Millisecond {
+		t.Fatalf("expected wait to throttle, took %v", elapsed)
+	}
+}
+
+func TestTokenBucketRateLimiter_WaitContext(t *testing.T) {
+	limiter := NewTokenBucketRateLimiter(1, 1)
+	if err := limiter.Wait(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := limiter.Wait(ctx); err == nil {
+		t.Fatal("expected wait to fail when deadline is shorter than the wait")
+	}
+}
+
+func TestTokenBucketRateLimiter_WaitCanceled(t *testing.T) {
+	clock := &fakeClock{now: time.Now()}
+	limiter := newTokenBucketRateLimiter(1, 1, clock.Now)
+	if !limiter.TryAccept() {
+		t.Fatal("expected first token to be available")
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := limiter.Wait(ctx); err == nil {
+		t.Fatal("expected wait to fail on canceled context")
+	}
+	// 失败的等待需要归还令牌, 一秒后可以获取到令牌
+	clock.Step(time.Second)
+	if !limiter.TryAccept() {
+		t.Fatal("expected canceled wait to return its token")
+	}
+}
+
+func TestNewTokenBucketRateLimiter_NonPositiveQPS(t *testing.T) {
+	for _, qps := range []float32{0, -1} {
+		limiter := NewTokenBucketRateLimiter(qps, 1)
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		for i := 0; i < 10; i++ {
+			if err := limiter.Wait(ctx); err != nil {
+				t.Fatalf("qps %v: unexpected error %v", qps, err)
+			}
+		}
+		cancel()
+		if !limiter.TryAccept() {
+			t.Errorf("qps %v: expected no limit", qps)
+		}
+	}
+}
+
+func TestNewRateLimiter(t *testing.T) {
+	limiter := NewRateLimiter()
+	for i := 0; i < 10; i++ {
+		if !limiter.TryAccept() {
+			t.Fatal("expected NewRateLimiter not to limit requests")
+		}
+	}
+}
+
+func TestNewRESTClientFor_RateLimiter(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	client, err := NewRESTClientFor(&Config{Host: server.URL, QPS: 50, Burst: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	limiter := client.GetRateLimiter()
+	if limiter == nil || limiter.QPS() != 50 {
+		t.Fatalf("expected rate limiter with qps 50, got %v", limiter)
+	}
+	start := time.Now()
+	for i := 0; i < 4; i++ {
+		if _, err := client.Get().Path("/").DoRaw(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Fatalf("expected requests to be throttled, took %v", elapsed)
+	}
+
+	custom := NewTokenBucketRateLimiter(1, 1)
+	client, err = NewRESTClientFor(&Config{Host: server.URL, QPS: 50, RateLimiter: custom})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if client.GetRateLimiter() != custom {
+		t.Fatal("expected Config.RateLimiter to take precedence over QPS")
+	}
+}
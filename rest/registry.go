@@ -0,0 +1,79 @@
+package rest
+
+import (
+	"strings"
+	"sync"
+)
+
+// CodecRegistry 根据 Content-Type 选择 Marshaler
+type CodecRegistry struct {
+	mu     sync.RWMutex
+	codecs map[string]Marshaler
+}
+
+// DefaultCodecRegistry 内置 JSON, XML, YAML, msgpack, protobuf 与表单
+var DefaultCodecRegistry = newDefaultCodecRegistry()
+
+func NewCodecRegistry() *CodecRegistry {
+	return &CodecRegistry{
+		codecs: make(map[string]Marshaler),
+	}
+}
+
+func newDefaultCodecRegistry() *CodecRegistry {
+	registry := NewCodecRegistry()
+	json := NewJsonMarshaler()
+	for _, mt := range []string{"application/json", "application/x-ndjson", "application/x-json-stream"} {
+		registry.Register(mt, json)
+	}
+	xml := NewXmlMarshaler()
+	for _, mt := range []string{"application/xml", "text/xml"} {
+		registry.Register(mt, xml)
+	}
+	yaml := NewYamlMarshaler()
+	for _, mt := range []string{"application/yaml", "application/x-yaml", "text/yaml", "text/x-yaml"} {
+		registry.Register(mt, yaml)
+	}
+	msgpack := NewMsgpackMarshaler()
+	for _, mt := range []string{"application/msgpack", "application/x-msgpack", "application/vnd.msgpack"} {
+		registry.Register(mt, msgpack)
+	}
+	protobuf := NewProtobufMarshaler()
+	for _, mt := range []string{"application/x-protobuf", "application/protobuf", "application/vnd.google.protobuf"} {
+		registry.Register(mt, protobuf)
+	}
+	registry.Register("application/x-www-form-urlencoded", NewFormMarshaler())
+	return registry
+}
+
+// RegisterCodec 向 DefaultCodecRegistry 注册 Marshaler
+func RegisterCodec(mediaType string, m Marshaler) {
+	DefaultCodecRegistry.Register(mediaType, m)
+}
+
+// Register 注册 mediaType 对应的 Marshaler, 已存在时覆盖
+func (c *CodecRegistry) Register(mediaType string, m Marshaler) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.codecs[strings.ToLower(mediaType)] = m
+}
+
+// Lookup 根据 Content-Type 查找 Marshaler, 忽略参数
+// 未注册的 application/vnd.xxx+json 等结构化后缀使用后缀对应的 Marshaler
+func (c *CodecRegistry) Lookup(contentType string) (Marshaler, bool) {
+	mt := mediaType(contentType)
+	if len(mt) == 0 {
+		return nil, false
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if m, ok := c.codecs[mt]; ok {
+		return m, true
+	}
+	if i := strings.LastIndexByte(mt, '+'); i >= 0 {
+		if m, ok := c.codecs["application/"+mt[i+1:]]; ok {
+			return m, true
+		}
+	}
+	return nil, false
+}
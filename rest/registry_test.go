@@ -0,0 +1,165 @@
+package rest
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCodecRegistry_Lookup(t *testing.T) {
+	registry := NewCodecRegistry()
+	json := NewJsonMarshaler()
+	registry.Register("application/json", json)
+
+	for _, contentType := range []string{"application/json", "Application/JSON; charset=utf-8", "application/vnd.api+json", "application/problem+json"} {
+		if m, ok := registry.Lookup(contentType); !ok || m != json {
+			t.Errorf("expected json codec for %q", contentType)
+		}
+	}
+	for _, contentType := range []string{"", "text/plain", "application/vnd.api+xml"} {
+		if _, ok := registry.Lookup(contentType); ok {
+			t.Errorf("unexpected codec for %q", contentType)
+		}
+	}
+}
+
+func TestDefaultCodecRegistry_RoundTrip(t *testing.T) {
+	type item struct {
+		Name  string `json:"name" xml:"name" yaml:"name" msgpack:"name" param:"name"`
+		Count int    `json:"count" xml:"count" yaml:"count" msgpack:"count" param:"count"`
+	}
+	for _, contentType := range []string{"application/json", "application/xml", "application/yaml", "application/msgpack", "application/x-www-form-urlencoded"} {
+		m, ok := DefaultCodecRegistry.Lookup(contentType)
+		if !ok {
+			t.Fatalf("missing codec for %s", contentType)
+		}
+		data, err := m.Marshal(&item{Name: "a b", Count: 2})
+		if err != nil {
+			t.Fatalf("%s: %v", contentType, err)
+		}
+		var out item
+		if err := m.Unmarshal(data, &out); err != nil {
+			t.Fatalf("%s: %v", contentType, err)
+		}
+		if out.Name != "a b" || out.Count != 2 {
+			t.Errorf("%s: unexpected round trip %+v", contentType, out)
+		}
+	}
+}
+
+func TestRequest_NegotiatedCodec(t *testing.T) {
+	type user struct {
+		XMLName xml.Name `json:"-" xml:"user"`
+		Name    string   `json:"name" xml:"name"`
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		switch r.URL.Path {
+		case "/json":
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"name":"json"}`))
+		case "/xml":
+			w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+			_, _ = w.Write([]byte(`<user><name>xml</name></user>`))
+		case "/echo":
+			w.Header().Set("Content-Type", r.Header.Get("Content-Type"))
+			_, _ = w.Write(body)
+		case "/untyped":
+			_, _ = w.Write([]byte(`{"name":"fallback"}`))
+		}
+	}))
+	defer server.Close()
+
+	client, err := NewRESTClientFor(&Config{Host: server.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for path, expected := range map[string]string{"/json": "json", "/xml": "xml", "/untyped": "fallback"} {
+		var out user
+		if err := client.Get().Path(path).Do(context.Background()).Into(&out); err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+		if out.Name != expected {
+			t.Errorf("%s: expected %q, got %q", path, expected, out.Name)
+		}
+	}
+
+	var out user
+	err = client.Post().Path("/echo").SetHeader("Content-Type", "application/xml").
+		Body(&user{Name: "echo"}).Do(context.Background()).Into(&out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.Name != "echo" {
+		t.Errorf("unexpected echo %+v", out)
+	}
+
+	result := client.Post().Path("/echo").SetHeader("Content-Type", "text/plain").Body(&user{Name: "plain"}).Do(context.Background())
+	if result.Error() != nil {
+		t.Fatal(result.Error())
+	}
+	if raw, _ := result.Raw(); string(raw) != `{"name":"plain"}` {
+		t.Errorf("expected fallback codec for unknown content type, got %s", raw)
+	}
+}
+
+func TestRequest_NoCodec(t *testing.T) {
+	client, err := NewRESTClientFor(&Config{Host: "http://localhost", ContentConfig: ContentConfig{Codecs: NewCodecRegistry()}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = client.Post().SetHeader("Content-Type", "text/plain").Body(struct{}{}).Do(context.Background()).Error()
+	if err == nil {
+		t.Fatal("expected missing serializer error")
+	}
+}
+
+// upperMarshaler 用于区分是否使用了 ContentConfig.Codec
+type upperMarshaler struct {
+	Marshaler
+}
+
+func (m upperMarshaler) Marshal(obj interface{}) ([]byte, error) {
+	data, err := m.Marshaler.Marshal(obj)
+	return bytes.ToUpper(data), err
+}
+
+func TestRequest_ConfiguredCodecPrecedence(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		_, _ = w.Write(body)
+	}))
+	defer server.Close()
+
+	client, err := NewRESTClientFor(&Config{Host: server.URL, ContentConfig: ContentConfig{
+		ContentType: "application/json",
+		Codec:       upperMarshaler{NewJsonMarshaler()},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	type item struct {
+		Name string `json:"name" xml:"name"`
+	}
+	// 配置的 Codec 优先于注册表中的 JSON Marshaler
+	raw, err := client.Post().SetHeader("Content-Type", "application/json; charset=utf-8").Body(&item{Name: "a"}).DoRaw(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(raw) != `{"NAME":"A"}` {
+		t.Errorf("expected configured codec for its content type, got %s", raw)
+	}
+	// 其他类型仍从注册表中查找
+	raw, err = client.Post().SetHeader("Content-Type", "application/xml").Body(&item{Name: "a"}).DoRaw(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(raw) != `<item><name>a</name></item>` {
+		t.Errorf("expected registry codec for other content types, got %s", raw)
+	}
+}
@@ -9,23 +9,41 @@ import (
 	"net/http"
 	"net/url"
 	"path"
+	"regexp"
+	"strings"
 	"time"
+
+	"go.opentelemetry.io/otel/trace"
 )
 
 type Request struct {
 	c *Client
 
-	timeout     time.Duration
+	timeout time.Duration
+	// timeoutSet 通过 Timeout 显式设置, Stream 只使用显式设置的超时时间
+	timeoutSet bool
+	// streaming Stream 请求不使用 http.Client.Timeout, 由 ctx 控制
+	streaming   bool
 	rateLimiter RateLimiter
 	// url params
-	verb       string
+	verb string
+	// pathPrefix, subpath, suffix 均为转义后的路径
 	pathPrefix string
+	subpath    string
+	suffix     string
+	pathParams map[string]string
 	params     url.Values
 	headers    http.Header
 	// output
-	err   error
-	body  io.Reader
-	retry WithRetry
+	err        error
+	body       io.Reader
+	maxRetries int
+	retryFn    func(maxRetries int) *withRetry
+	retry      WithRetry
+	metrics    Metrics
+	hedge      *HedgePolicy
+	// watchRetries Watch 连续重连的次数上限, 0 表示不限制
+	watchRetries int
 
 	coder Marshaler
 }
@@ -54,17 +72,49 @@ func (r *Request) Timeout(d time.Duration) *Request {
 		return r
 	}
 	r.timeout = d
+	r.timeoutSet = true
+
+	return r
+}
 
+// WithRetry 使用 retry 等待重试, 覆盖 Config.NewRetry, 是否重试仍由 RetryPolicy 与 MaxRetries 决定
+func (r *Request) WithRetry(retry WithRetry) *Request {
+	if r.err != nil {
+		return r
+	}
+	r.retry = retry
+	return r
+}
+
+// MaxRetries 设置最大重试次数, 覆盖 Config.MaxRetries
+func (r *Request) MaxRetries(max int) *Request {
+	if r.err != nil {
+		return r
+	}
+	if max < 0 {
+		max = 0
+	}
+	r.maxRetries = max
 	return r
 }
 
+// Prefix 在客户端基础路径之后追加路径, 与 Path 相同会再次转义 %, 已转义的路径使用 RawPath
 func (r *Request) Prefix(segments ...string) *Request {
 	if r.err != nil {
 		return r
 	}
 	// segments = [a,b,c]
 	// pathPrefix = /api/v1/ + "/a/b/c"
-	r.pathPrefix = path.Join(r.pathPrefix, path.Join(segments...))
+	r.pathPrefix = path.Join(r.pathPrefix, escapePath(path.Join(segments...)))
+	return r
+}
+
+// Suffix 在请求路径之后追加路径, 与 Path 相同会再次转义 %
+func (r *Request) Suffix(segments ...string) *Request {
+	if r.err != nil {
+		return r
+	}
+	r.suffix = path.Join(r.suffix, escapePath(path.Join(segments...)))
 	return r
 }
 
@@ -79,7 +129,9 @@ func (r *Request) RequestURI(uri string) *Request {
 		return r
 	}
 
-	r.pathPrefix = locator.Path
+	r.pathPrefix = locator.EscapedPath()
+	r.subpath = ""
+	r.suffix = ""
 
 	if len(locator.Query()) > 0 {
 		if r.params == nil {
@@ -92,12 +144,68 @@ func (r *Request) RequestURI(uri string) *Request {
 	return r
 }
 
-// Path 设置请求路径
-func (r *Request) Path(url string) *Request {
-	r.pathPrefix = url
+// Path 设置请求路径, 拼接在 Prefix 之后, 每一段路径会被转义, {name} 为路径参数
+// 已转义的内容会被再次转义, 例如 a%2Fb 发送为 a%252Fb, 已转义的路径使用 RawPath
+func (r *Request) Path(p string) *Request {
+	if r.err != nil {
+		return r
+	}
+	r.subpath = escapePath(p)
 	return r
 }
 
+// RawPath 设置已转义的请求路径, 与 Path 相同拼接在 Prefix 之后, 但不会再次转义
+func (r *Request) RawPath(p string) *Request {
+	if r.err != nil {
+		return r
+	}
+	if _, err := url.PathUnescape(p); err != nil {
+		r.err = fmt.Errorf("invalid escaped path %q: %w", p, err)
+		return r
+	}
+	r.subpath = p
+	return r
+}
+
+// Name 追加一段路径, 其中的 / 也会被转义, 用于拼接 ID 等外部输入
+func (r *Request) Name(segment string) *Request {
+	if r.err != nil {
+		return r
+	}
+	if err := validatePathSegment(segment); err != nil {
+		r.err = err
+		return r
+	}
+	r.subpath = path.Join(r.subpath, url.PathEscape(segment))
+	return r
+}
+
+// PathParam 设置路径模板中 {name} 的值, 例如 Path("/users/{id}").PathParam("id", "1")
+func (r *Request) PathParam(name, value string) *Request {
+	if r.err != nil {
+		return r
+	}
+	if err := validatePathSegment(value); err != nil {
+		r.err = fmt.Errorf("path parameter %s: %w", name, err)
+		return r
+	}
+	if r.pathParams == nil {
+		r.pathParams = make(map[string]string)
+	}
+	r.pathParams[name] = value
+	return r
+}
+
+func validatePathSegment(segment string) error {
+	switch segment {
+	case "":
+		return fmt.Errorf("path segment must not be empty")
+	case ".", "..":
+		return fmt.Errorf("invalid path segment %q", segment)
+	}
+	return nil
+}
+
 // Param 请求参数
 func (r *Request) Param(name, value string) *Request {
 	if r.err != nil {
@@ -133,15 +241,47 @@ func (r *Request) setParam(name, value string) *Request {
 	return r
 }
 
-// URL 生成URL对象
+// UntemplatedRoute 请求路径不包含 {name} 参数时 Route 的返回值, 避免原始路径导致标签数量无限增长
+const UntemplatedRoute = "<untemplated>"
+
+// Route 替换路径参数之前的路径模板, 用于按路由统计与记录日志
+// 路径中没有 {name} 参数时返回 UntemplatedRoute
+func (r *Request) Route() string {
+	p := r.template()
+	if !pathParamRe.MatchString(p) {
+		return UntemplatedRoute
+	}
+	return p
+}
+
+// template 拼接后的路径, 可能包含 {name} 参数
+func (r *Request) template() string {
+	p := r.pathPrefix
+	if len(r.subpath) > 0 {
+		p = joinPath(p, r.subpath)
+	}
+	if len(r.suffix) > 0 {
+		p = joinPath(p, r.suffix)
+	}
+	return p
+}
+
+// URL 生成URL对象, 未设置的路径参数保留为 {name}
 func (r *Request) URL() *url.URL {
-	path := r.pathPrefix
+	u, _ := r.url()
+	return u
+}
+
+// url 生成URL对象, 路径参数未设置时返回错误
+func (r *Request) url() (*url.URL, error) {
+	p, err := expandPath(r.template(), r.pathParams)
 
 	finalURL := &url.URL{}
 	if r.c.base != nil {
 		*finalURL = *r.c.base
 	}
-	finalURL.Path = path
+	finalURL.RawPath = p
+	finalURL.Path, _ = url.PathUnescape(p)
 
 	query := url.Values{}
 	for key, values := range r.params {
@@ -151,10 +291,10 @@ func (r *Request) URL() *url.URL {
 	}
 
 	finalURL.RawQuery = query.Encode()
-	return finalURL
+	return finalURL, err
 }
 
-// Body 请求体
+// Body 请求体, 实现 io.Seeker 的请求体 (例如 *os.File) 重试时回到第一次请求前的位置, 请求结束后关闭
 func (r *Request) Body(obj interface{}) *Request {
 	if r.err != nil {
 		return r
@@ -166,7 +306,13 @@ func (r *Request) Body(obj interface{}) *Request {
 	case io.Reader:
 		r.body = t
 	default:
-		b, err := r.coder.Marshal(obj)
+		contentType := r.headers.Get("Content-Type")
+		coder := r.codecFor(contentType)
+		if coder == nil {
+			r.err = fmt.Errorf("serializer for %s doesn't exist", contentType)
+			return r
+		}
+		b, err := coder.Marshal(obj)
 		if err != nil {
 			r.err = err
 		}
@@ -175,16 +321,81 @@ func (r *Request) Body(obj interface{}) *Request {
 	return r
 }
 
+// codecFor 选择 Content-Type 对应的 Marshaler
+// ContentConfig.Codec 优先用于 ContentConfig.ContentType, 其他类型从 Codecs 中查找, 找不到时仍使用 ContentConfig.Codec
+func (r *Request) codecFor(contentType string) Marshaler {
+	if r.coder != nil {
+		mt := mediaType(contentType)
+		if len(mt) == 0 || mt == mediaType(r.c.Config.ContentType) {
+			return r.coder
+		}
+	}
+	registry := r.c.Config.Codecs
+	if registry == nil {
+		registry = DefaultCodecRegistry
+	}
+	if coder, ok := registry.Lookup(contentType); ok {
+		return coder
+	}
+	if r.coder != nil {
+		return r.coder
+	}
+	coder, _ := registry.Lookup(r.c.Config.ContentType)
+	return coder
+}
+
+// FormData application/x-www-form-urlencoded 请求体
+// 支持 url.Values, map[string]string 以及带有 param tag 的结构体
+func (r *Request) FormData(obj interface{}) *Request {
+	if r.err != nil {
+		return r
+	}
+	var values url.Values
+	switch t := obj.(type) {
+	case url.Values:
+		values = t
+	case map[string][]string:
+		values = t
+	case map[string]string:
+		values = make(url.Values, len(t))
+		for key, value := range t {
+			values.Set(key, value)
+		}
+	default:
+		var err error
+		values, err = NewParameterCodec().EncodeParameters(obj)
+		if err != nil {
+			r.err = err
+			return r
+		}
+	}
+	r.body = strings.NewReader(values.Encode())
+	return r.SetHeader("Content-Type", "application/x-www-form-urlencoded")
+}
+
+// Multipart multipart/form-data 请求体, 文件内容流式发送, 因此请求不会重试
+func (r *Request) Multipart(form *MultipartForm) *Request {
+	if r.err != nil {
+		form.close()
+		return r
+	}
+	body := newMultipartBody(form)
+	r.body = body
+	return r.SetHeader("Content-Type", body.ContentType())
+}
+
 // Do 发起请求
 func (r *Request) Do(ctx context.Context) Result {
+	ctx, span := r.startSpan(ctx)
 	var result Result
 	err := r.request(ctx, func(request *http.Request, response *http.Response) {
 		// 返回结果
 		result = r.transformResponse(response, request)
 	})
 	if err != nil {
-		return Result{err: err}
+		result = Result{err: err}
 	}
+	endSpan(span, result.statusCode, result.err)
 	return result
 }
 
@@ -194,62 +405,212 @@ func (r *Request) DoRaw(ctx context.Context) ([]byte, error) {
 	return result.Raw()
 }
 
+// Stream 返回响应体, 由调用方负责关闭, 适用于下载大文件或分块传输的接口
+// 非 2xx 响应与 Do 一样返回 StatusError
+// Config.Timeout 不限制读取时间, 通过 ctx 取消, 显式调用 Request.Timeout 时覆盖整个读取过程
+func (r *Request) Stream(ctx context.Context) (io.ReadCloser, error) {
+	body, _, err := r.stream(ctx)
+	return body, err
+}
+
+func (r *Request) stream(ctx context.Context) (io.ReadCloser, http.Header, error) {
+	ctx, span := r.startSpan(ctx)
+	r.streaming = true
+	cancel := context.CancelFunc(func() {})
+	if r.timeoutSet && r.timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, r.timeout)
+	}
+	var body io.ReadCloser
+	var header http.Header
+	var code int
+	var statusErr error
+	err := r.doRequest(ctx, func(req *http.Request, resp *http.Response) {
+		code = resp.StatusCode
+		if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+			statusErr = r.transformResponse(resp, req).Error()
+			return
+		}
+		body = resp.Body
+		header = resp.Header
+	})
+	if err == nil {
+		err = statusErr
+	}
+	if err != nil || body == nil {
+		cancel()
+		endSpan(span, code, err)
+		return nil, nil, err
+	}
+	return &streamReadCloser{ReadCloser: body, cancel: cancel, span: span, code: code}, header, nil
+}
+
+// streamReadCloser 关闭时释放 Request.Timeout 的 context 并结束 span
+type streamReadCloser struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+	span   trace.Span
+	code   int
+}
+
+func (s *streamReadCloser) Close() error {
+	err := s.ReadCloser.Close()
+	s.cancel()
+	endSpan(s.span, s.code, nil)
+	return err
+}
+
 // newHTTPRequest 构造http.Request
 func (r *Request) newHTTPRequest(ctx context.Context) (*http.Request, error) {
 	u := r.URL().String()
-	req, err := http.NewRequest(r.verb, u, r.body)
+	body := r.body
+	// 可以重置的请求体 (例如 *os.File) 不交给 http.Client 关闭, 重试时还需要读取, 由 doRequest 在结束时关闭
+	if _, ok := r.rewindableCloser(); ok {
+		body = ioutil.NopCloser(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, r.verb, u, body)
 	if err != nil {
 		return nil, err
 	}
-	req.Header = r.headers
-	req.WithContext(ctx)
+	if r.headers != nil {
+		req.Header = CloneHeader(r.headers)
+	}
 	return req, nil
 }
 
 func (r *Request) request(ctx context.Context, fn func(*http.Request, *http.Response)) error {
-	start := time.Now()
-	defer func() {
-		_ = time.Since(start)
-	}()
+	// 超时取消 context
+	if r.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.timeout)
+		defer cancel()
+	}
+	return r.doRequest(ctx, fn)
+}
+
+// doRequest 发起请求并按照重试策略重试, fn 负责处理并关闭最终的响应
+func (r *Request) doRequest(ctx context.Context, fn func(*http.Request, *http.Response)) error {
 	if r.err != nil {
+		r.closeBody()
 		return r.err
 	}
+	// 检查路径参数是否都已设置
+	u, err := r.url()
+	if err != nil {
+		r.closeBody()
+		return err
+	}
+	info := RequestInfo{Verb: r.verb, Host: u.Host, Route: r.Route()}
+	ctx = context.WithValue(ctx, requestInfoKey{}, info)
+	start := time.Now()
+	defer func() {
+		r.metrics.RequestLatency(ctx, info, time.Since(start))
+	}()
 
 	client := r.c.Client
 	if client == nil {
 		client = http.DefaultClient
 	}
-	// 超时取消 context
-	if r.timeout > 0 {
-		var cancel context.CancelFunc
-		ctx, cancel = context.WithTimeout(ctx, r.timeout)
-		defer cancel()
+	// http.Client.Timeout 包括读取响应体的时间, 会中断大文件下载
+	if r.streaming && client.Timeout > 0 {
+		streamClient := *client
+		streamClient.Timeout = 0
+		client = &streamClient
 	}
-	// 构造Request
-	req, err := r.newHTTPRequest(ctx)
-	if err != nil {
-		return err
+
+	retry := r.retryFn(r.maxRetries)
+	custom := r.retry
+	if custom == nil && r.c.newRetry != nil {
+		custom = r.c.newRetry()
+	}
+	if custom != nil {
+		retry.use(custom)
 	}
+	for attempt := 0; ; attempt++ {
+		if attempt > 0 {
+			r.metrics.RequestRetry(ctx, info)
+			traceRetry(ctx, attempt)
+		}
+		// 重试前等待退避时间并重置请求体
+		if err := retry.before(ctx, r); err != nil {
+			r.closeBody()
+			return r.wrapError(ctx, err)
+		}
+		// 限流
+		if r.rateLimiter != nil {
+			waitStart := time.Now()
+			err := r.rateLimiter.Wait(ctx)
+			r.metrics.RateLimiterLatency(ctx, info, time.Since(waitStart))
+			if err != nil {
+				r.closeBody()
+				return r.wrapError(ctx, err)
+			}
+		}
+		// 构造Request
+		req, err := r.newHTTPRequest(ctx)
+		if err != nil {
+			r.closeBody()
+			return err
+		}
+		r.injectTrace(ctx, req)
+		countRequestBody(ctx, r.metrics, info, req)
+
+		var resp *http.Response
+		if r.canHedge(req) {
+			resp, err = r.hedgedDo(ctx, client, req, info)
+		} else {
+			resp, err = client.Do(req)
+		}
+		if resp != nil {
+			r.metrics.RequestResult(ctx, info, resp.StatusCode)
+			countResponseBody(ctx, r.metrics, info, resp)
+		} else {
+			r.metrics.RequestResult(ctx, info, 0)
+		}
+		done := func() bool {
+			if retry.isNextRetry(ctx, r, req, resp, err) {
+				readAndCloseResponseBody(resp)
+				return false
+			}
+
+			f := func(req *http.Request, resp *http.Response) {
+				if resp == nil {
+					return
+				}
+				fn(req, resp)
+			}
 
-	resp, err := client.Do(req)
-	// TODO:// 重试 限流 Metric
-	done := func() bool {
+			f(req, resp)
 
-		f := func(req *http.Request, resp *http.Response) {
-			if resp == nil {
-				return
+			return true
+		}()
+		if done {
+			if closer, ok := r.rewindableCloser(); ok {
+				_ = closer.Close()
 			}
-			fn(req, resp)
+			return r.wrapError(ctx, err)
 		}
+	}
+}
 
-		f(req, resp)
+// rewindableCloser 请求体同时实现 io.Seeker 与 io.Closer 时返回 io.Closer
+func (r *Request) rewindableCloser() (io.Closer, bool) {
+	if _, ok := r.body.(io.Seeker); !ok {
+		return nil, false
+	}
+	closer, ok := r.body.(io.Closer)
+	return closer, ok
+}
 
-		return true
-	}()
-	if done {
-		return err
+// closeBody 请求体未交给 http.Client 就失败时关闭请求体, 与 http.Client 的行为保持一致
+func (r *Request) closeBody() {
+	if closer, ok := r.body.(io.Closer); ok {
+		_ = closer.Close()
 	}
-	return nil
+}
+
+// wrapError 区分客户端超时与调用方取消
+func (r *Request) wrapError(ctx context.Context, err error) error {
+	return wrapRequestError(ctx, r.verb, r.URL().String(), err)
 }
 
 // transformResponse 处理返回结果
@@ -257,6 +618,7 @@ func (r *Request) transformResponse(resp *http.Response, req *http.Request) Resu
 	var body []byte
 	// 先读取Body
 	if resp.Body != nil {
+		defer resp.Body.Close()
 		out, err := ioutil.ReadAll(resp.Body)
 		switch err.(type) {
 		case nil:
@@ -267,15 +629,27 @@ func (r *Request) transformResponse(resp *http.Response, req *http.Request) Resu
 		}
 	}
 
-	coder := r.coder
-
 	contentType := resp.Header.Get("Content-Type")
 	if len(contentType) == 0 {
 		contentType = r.c.Config.ContentType
 	}
+	coder := r.codecFor(contentType)
+	var err error
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		status := newStatusError(req, resp.StatusCode, body, coder)
+		err = status
+		decoder := r.c.Config.ErrorDecoder
+		if decoder == nil {
+			decoder = DefaultErrorDecoder
+		}
+		if decoded := decoder.Decode(contentType, body, status); decoded != nil {
+			err = decoded
+		}
+	}
 	return Result{
 		body:        body,
 		contentType: contentType,
+		err:         err,
 		statusCode:  resp.StatusCode,
 		codecer:     coder,
 	}
@@ -290,7 +664,10 @@ func NewRequest(c *Client) *Request {
 	}
 	coder := c.Config.Codec
 
-	var pathPrefix string
+	pathPrefix := "/"
+	if c.base != nil {
+		pathPrefix = path.Join("/", c.base.EscapedPath(), escapePath(c.apiPath))
+	}
 
 	r := &Request{
 		c:           c,
@@ -298,7 +675,14 @@ func NewRequest(c *Client) *Request {
 		timeout:     timeout,
 		pathPrefix:  pathPrefix,
 		coder:       coder,
-		retry:       NewWithRetry(10),
+		maxRetries:  c.maxRetries,
+		retryFn: func(maxRetries int) *withRetry {
+			return newWithRetry(maxRetries, c.backoff, c.retryPolicy)
+		},
+		metrics: c.metrics,
+	}
+	if r.metrics == nil {
+		r.metrics = NoopMetrics{}
 	}
 	switch {
 	case len(c.Config.AcceptContentTypes) > 0:
@@ -309,3 +693,44 @@ func NewRequest(c *Client) *Request {
 	}
 	return r
 }
+
+// escapePath 转义每一段路径, 保留 / 与路径参数的 {}
+func escapePath(p string) string {
+	segments := strings.Split(p, "/")
+	for i, segment := range segments {
+		segments[i] = braceReplacer.Replace(url.PathEscape(segment))
+	}
+	return strings.Join(segments, "/")
+}
+
+var (
+	braceReplacer = strings.NewReplacer("%7B", "{", "%7D", "}")
+	pathParamRe   = regexp.MustCompile(`\{([^{}/]+)\}`)
+)
+
+// expandPath 使用转义后的参数替换路径模板中的 {name}
+func expandPath(template string, params map[string]string) (string, error) {
+	var missing []string
+	expanded := pathParamRe.ReplaceAllStringFunc(template, func(placeholder string) string {
+		name := placeholder[1 : len(placeholder)-1]
+		value, ok := params[name]
+		if !ok {
+			missing = append(missing, name)
+			return placeholder
+		}
+		return url.PathEscape(value)
+	})
+	if len(missing) > 0 {
+		return template, fmt.Errorf("path parameters not set: %s", strings.Join(missing, ", "))
+	}
+	return expanded, nil
+}
+
+// joinPath 与 path.Join 相同, 但保留 elem 末尾的 /
+func joinPath(base, elem string) string {
+	joined := path.Join(base, elem)
+	if strings.HasSuffix(elem, "/") && !strings.HasSuffix(joined, "/") {
+		joined += "/"
+	}
+	return joined
+}
@@ -1,8 +1,18 @@
 package rest
 
 import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
 	"mime"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 func TestContentType(t *testing.T) {
@@ -16,3 +26,230 @@ func TestContentType(t *testing.T) {
 	t.Log(params)
 
 }
+
+func TestRequest_Stream(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.URL.Path == "/missing" {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte("no such export"))
+			return
+		}
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		flusher := w.(http.Flusher)
+		for i := 0; i < 3; i++ {
+			_, _ = fmt.Fprintf(w, "chunk-%d\n", i)
+			flusher.Flush()
+		}
+	}))
+	defer server.Close()
+
+	limiter := NewTokenBucketRateLimiter(1000, 1)
+	client, err := NewRESTClientFor(&Config{
+		Host:        server.URL,
+		BearerToken: "token",
+		RateLimiter: limiter,
+		MaxRetries:  1,
+		Backoff:     Backoff{Duration: time.Millisecond},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body, err := client.Get().Path("/export").Timeout(time.Second).Stream(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	reader := bufio.NewReader(body)
+	line, err := reader.ReadString('\n')
+	if err != nil || line != "chunk-0\n" {
+		t.Fatalf("unexpected first chunk %q %v", line, err)
+	}
+	rest, err := ioutil.ReadAll(reader)
+	if err != nil || string(rest) != "chunk-1\nchunk-2\n" {
+		t.Fatalf("unexpected remaining chunks %q %v", rest, err)
+	}
+	if err := body.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if calls != 2 {
+		t.Fatalf("expected stream to be retried, got %d calls", calls)
+	}
+
+	body, err = client.Get().Path("/missing").Stream(context.Background())
+	if body != nil || !IsNotFound(err) {
+		t.Fatalf("expected not found error, got %v", err)
+	}
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) || statusErr.Body != "no such export" {
+		t.Fatalf("expected status error with body, got %v", err)
+	}
+}
+
+func TestRequest_StreamTimeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("partial"))
+		w.(http.Flusher).Flush()
+		select {
+		case <-time.After(time.Second):
+		case <-r.Context().Done():
+		}
+	}))
+	defer server.Close()
+
+	client, _ := NewRESTClientFor(&Config{Host: server.URL})
+	body, err := client.Get().Path("/").Timeout(50 * time.Millisecond).Stream(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer body.Close()
+	start := time.Now()
+	if _, err := ioutil.ReadAll(body); err == nil {
+		t.Fatal("expected read to fail when Request.Timeout expires")
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Fatalf("expected timeout to stop the stream, took %v", elapsed)
+	}
+}
+
+func TestRequest_StreamIgnoresClientTimeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for i := 0; i < 4; i++ {
+			_, _ = fmt.Fprintf(w, "chunk-%d\n", i)
+			w.(http.Flusher).Flush()
+			time.Sleep(30 * time.Millisecond)
+		}
+	}))
+	defer server.Close()
+
+	// Config.Timeout 小于整个下载的时间
+	client, _ := NewRESTClientFor(&Config{Host: server.URL, Timeout: 50 * time.Millisecond})
+	body, err := client.Get().Path("/").Stream(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer body.Close()
+	data, err := ioutil.ReadAll(body)
+	if err != nil || strings.Count(string(data), "chunk-") != 4 {
+		t.Fatalf("expected the whole stream, got %q %v", data, err)
+	}
+	// 普通请求仍然受 Config.Timeout 限制
+	if _, err := client.Get().Path("/").DoRaw(context.Background()); err == nil {
+		t.Fatal("expected Do to honor Config.Timeout")
+	}
+}
+
+func TestRequest_URL(t *testing.T) {
+	newClient := func(host, basePath string) Interface {
+		client, err := NewRESTClientFor(&Config{Host: host, Path: basePath})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return client
+	}
+	cases := []struct {
+		name    string
+		request *Request
+		url     string
+	}{
+		{"path", newClient("http://localhost", "").Get().Path("/users"), "http://localhost/users"},
+		{"base path", newClient("http://localhost", "/api/v2").Get().Path("/users"), "http://localhost/api/v2/users"},
+		{"host path", newClient("http://localhost/gateway", "api/v2").Get().Path("users"), "http://localhost/gateway/api/v2/users"},
+		{"prefix", newClient("http://localhost", "/api").Get().Prefix("v1", "admin").Path("/users"), "http://localhost/api/v1/admin/users"},
+		{"name", newClient("http://localhost", "/api").Get().Path("/users").Name("a/b c").Suffix("orders"), "http://localhost/api/users/a%2Fb%20c/orders"},
+		{"escape", newClient("http://localhost", "").Get().Path("/files/report 2021?.csv"), "http://localhost/files/report%202021%3F.csv"},
+		{"escaped path", newClient("http://localhost", "").Get().Path("/files/a%2Fb"), "http://localhost/files/a%252Fb"},
+		{"raw path", newClient("http://localhost", "/api").Get().RawPath("/files/a%2Fb%20c").Suffix("raw"), "http://localhost/api/files/a%2Fb%20c/raw"},
+		{"trailing slash", newClient("http://localhost", "/api").Get().Path("/users/"), "http://localhost/api/users/"},
+		{"request uri", newClient("http://localhost", "/api").Get().RequestURI("/other/a%2Fb?x=1"), "http://localhost/other/a%2Fb?x=1"},
+	}
+	for _, c := range cases {
+		if got := c.request.URL().String(); got != c.url {
+			t.Errorf("%s: expected %s, got %s", c.name, c.url, got)
+		}
+	}
+
+	if err := newClient("http://localhost", "").Get().RawPath("/files/%zz").Do(context.Background()).Error(); err == nil {
+		t.Error("expected invalid escaped path to be rejected")
+	}
+	for _, name := range []string{"", ".", ".."} {
+		if err := newClient("http://localhost", "").Get().Path("/users").Name(name).Do(context.Background()).Error(); err == nil {
+			t.Errorf("expected error for name %q", name)
+		}
+	}
+}
+
+func TestRequest_EscapedPath(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(r.URL.EscapedPath()))
+	}))
+	defer server.Close()
+
+	client, err := NewRESTClientFor(&Config{Host: server.URL, Path: "/api/v2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := client.Get().Path("/users").Name("../admin").DoRaw(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(body) != "/api/v2/users/..%2Fadmin" {
+		t.Errorf("unexpected server path %s", body)
+	}
+	body, err = client.Get().RawPath("/files/a%2Fb").DoRaw(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(body) != "/api/v2/files/a%2Fb" {
+		t.Errorf("expected raw path to be sent as is, got %s", body)
+	}
+}
+
+func TestRequest_PathParam(t *testing.T) {
+	var paths []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.EscapedPath())
+	}))
+	defer server.Close()
+
+	client, err := NewRESTClientFor(&Config{Host: server.URL, Path: "/api"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	request := client.Get().Path("/users/{id}/orders/{orderId}").PathParam("id", "a/b").PathParam("orderId", "42")
+	if route := request.Route(); route != "/api/users/{id}/orders/{orderId}" {
+		t.Errorf("unexpected route %s", route)
+	}
+	if route := client.Get().Path("/users/1").Route(); route != UntemplatedRoute {
+		t.Errorf("expected untemplated route, got %s", route)
+	}
+	// URL 没有副作用, 缺少的参数在发送请求时报错
+	missing := client.Get().Path("/users/{id}")
+	if u := missing.URL(); u.Path != "/api/users/{id}" || missing.err != nil {
+		t.Errorf("expected URL not to record an error, got %s %v", u, missing.err)
+	}
+	if err := request.Do(context.Background()).Error(); err != nil {
+		t.Fatal(err)
+	}
+	if len(paths) != 1 || paths[0] != "/api/users/a%2Fb/orders/42" {
+		t.Errorf("unexpected server paths %v", paths)
+	}
+
+	err = client.Get().Path("/users/{id}/orders/{orderId}").PathParam("id", "1").Do(context.Background()).Error()
+	if err == nil || !strings.Contains(err.Error(), "orderId") {
+		t.Errorf("expected missing path parameter error, got %v", err)
+	}
+	if err := client.Get().Path("/users/{id}").PathParam("id", "..").Do(context.Background()).Error(); err == nil {
+		t.Error("expected invalid path parameter error")
+	}
+	if len(paths) != 1 {
+		t.Errorf("requests with invalid path parameters must not be sent, got %v", paths)
+	}
+}
@@ -4,10 +4,15 @@ import (
 	"net/http"
 	"net/url"
 	"strings"
+
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
 )
 
 var (
 	DefaultContentType = "application/json"
+	// DefaultBurst 配置了 QPS 但未配置 Burst 时使用的桶容量
+	DefaultBurst = 10
 )
 
 type HTTPClient interface {
@@ -25,14 +30,29 @@ type Interface interface {
 }
 
 type Client struct {
-	base        *url.URL
+	base *url.URL
+	// apiPath Config.Path, 拼接在 base 的路径之后
+	apiPath     string
 	rateLimiter RateLimiter
 	Config      ContentConfig
 	Client      *http.Client
+
+	maxRetries  int
+	backoff     Backoff
+	retryPolicy RetryPolicy
+	newRetry    func() WithRetry
+	metrics     Metrics
+	tracer      trace.Tracer
+	propagator  propagation.TextMapPropagator
+	// latencies 按路径模板记录的延迟, 用于 HedgePolicy.Percentile
+	latencies *latencyTracker
 }
 
 func (c *Client) GetRateLimiter() RateLimiter {
-	return nil
+	if c == nil {
+		return nil
+	}
+	return c.rateLimiter
 }
 
 func (c *Client) Post() *Request {
@@ -72,9 +92,11 @@ func NewRESTClient(baseURL *url.URL, config ContentConfig, rateLimiter RateLimit
 	base.Fragment = ""
 
 	return &Client{
-		base:   &base,
-		Config: config,
-		Client: client,
+		base:        &base,
+		rateLimiter: rateLimiter,
+		Config:      config,
+		Client:      client,
+		latencies:   newLatencyTracker(),
 	}, nil
 }
 
@@ -99,7 +121,30 @@ func NewRESTClientFor(config *Config) (Interface, error) {
 			httpClient.Timeout = config.Timeout
 		}
 	}
-	// TODO: rateLimiter
+	// 未指定限流器时根据 QPS/Burst 构造令牌桶, QPS <= 0 表示不限流
+	rateLimiter := config.RateLimiter
+	if rateLimiter == nil && config.QPS > 0 {
+		burst := config.Burst
+		if burst <= 0 {
+			burst = DefaultBurst
+		}
+		rateLimiter = NewTokenBucketRateLimiter(config.QPS, burst)
+	}
 
-	return NewRESTClient(baseURL, config.ContentConfig, nil, httpClient)
+	client, err := NewRESTClient(baseURL, config.ContentConfig, rateLimiter, httpClient)
+	if err != nil {
+		return nil, err
+	}
+	c := client.(*Client)
+	c.apiPath = config.Path
+	c.maxRetries = config.MaxRetries
+	c.backoff = config.Backoff
+	c.retryPolicy = config.RetryPolicy
+	c.newRetry = config.NewRetry
+	c.metrics = config.Metrics
+	if config.TracerProvider != nil {
+		c.tracer = config.TracerProvider.Tracer(tracerName)
+	}
+	c.propagator = config.Propagator
+	return c, nil
 }
@@ -0,0 +1,302 @@
+package rest
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"math"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// WithRetry 重试接口, 通过 Config.NewRetry 或 Request.WithRetry 替换请求重试前的等待
+// 发送请求前以本次请求的最大重试次数调用 MaxRetries, 每次重试前调用 Retry
+type WithRetry interface {
+	// MaxRetries 设置最大重试次数, 0 表示不重试
+	MaxRetries(max int)
+	// Retry 阻塞等待下一次重试前的退避时间, 优先使用服务端返回的 Retry-After
+	Retry()
+}
+
+// RetryPolicy 判断一次请求的结果是否可以重试
+type RetryPolicy func(req *http.Request, resp *http.Response, err error) bool
+
+// Backoff 指数退避
+type Backoff struct {
+	// Duration 第一次重试前的等待时间
+	Duration time.Duration
+	// Factor 每次重试等待时间的倍数
+	Factor float64
+	// Jitter 随机抖动的比例, 0.2 表示在 [d, 1.2d) 之间
+	Jitter float64
+	// Cap 最大等待时间, 同时限制服务端的 Retry-After, 为 0 时 Retry-After 使用 DefaultBackoff.Cap
+	Cap time.Duration
+}
+
+var DefaultBackoff = Backoff{
+	Duration: 100 * time.Millisecond,
+	Factor:   2,
+	Jitter:   0.2,
+	Cap:      10 * time.Second,
+}
+
+// Step 第 attempt 次重试 (从 0 开始) 需要等待的时间
+func (b Backoff) Step(attempt int) time.Duration {
+	d := float64(b.Duration)
+	if b.Factor > 1 {
+		d *= math.Pow(b.Factor, float64(attempt))
+	}
+	if b.Cap > 0 && d > float64(b.Cap) {
+		d = float64(b.Cap)
+	}
+	if b.Jitter > 0 {
+		d += d * b.Jitter * rand.Float64()
+	}
+	return time.Duration(d)
+}
+
+// DefaultRetryPolicy 连接错误, 429 与 5xx (501, 505 除外) 进行重试, 熔断打开时不重试
+// 非幂等请求 (POST, PATCH) 只在连接阶段失败, 即请求确定没有发出时重试,
+// 带有 Idempotency-Key 或 X-Idempotency-Key 头的请求视为幂等
+func DefaultRetryPolicy(req *http.Request, resp *http.Response, err error) bool {
+	if err != nil {
+		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			return false
+		}
+		// 熔断打开时重试只会继续失败
+		if errors.Is(err, ErrCircuitOpen) {
+			return false
+		}
+		if isDialError(err) {
+			return true
+		}
+		if req != nil && !isIdempotent(req) {
+			return false
+		}
+		var netErr net.Error
+		if errors.As(err, &netErr) {
+			return true
+		}
+		return errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)
+	}
+	if resp == nil || (req != nil && !isIdempotent(req)) {
+		return false
+	}
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests:
+		return true
+	case http.StatusNotImplemented, http.StatusHTTPVersionNotSupported:
+		return false
+	}
+	return resp.StatusCode >= http.StatusInternalServerError
+}
+
+// isIdempotent 与 net/http 一致, 幂等方法或带有 Idempotency-Key 的请求可以安全地重复发送
+func isIdempotent(req *http.Request) bool {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	if _, ok := req.Header["Idempotency-Key"]; ok {
+		return true
+	}
+	_, ok := req.Header["X-Idempotency-Key"]
+	return ok
+}
+
+// isDialError 建立连接失败, 请求没有发出
+func isDialError(err error) bool {
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
+type withRetry struct {
+	maxRetries int
+	attempts   int
+	// retries 已经等待过的退避次数
+	retries int
+	backoff Backoff
+	policy  RetryPolicy
+	// retryAfter 服务端通过 Retry-After 指定的等待时间
+	retryAfter time.Duration
+	// offset 第一次请求前请求体的位置, 重试时回到该位置
+	offset int64
+	// custom 调用方提供的 WithRetry, 替换默认的等待
+	custom WithRetry
+}
+
+var _ WithRetry = &withRetry{}
+
+// NewWithRetry 按 DefaultBackoff 等待的 WithRetry, 可以用于 Request.WithRetry
+func NewWithRetry(maxRetries int) WithRetry {
+	return newWithRetry(maxRetries, DefaultBackoff, DefaultRetryPolicy)
+}
+
+func newWithRetry(maxRetries int, backoff Backoff, policy RetryPolicy) *withRetry {
+	if backoff == (Backoff{}) {
+		backoff = DefaultBackoff
+	}
+	if policy == nil {
+		policy = DefaultRetryPolicy
+	}
+	return &withRetry{
+		maxRetries: maxRetries,
+		backoff:    backoff,
+		policy:     policy,
+	}
+}
+
+func (w *withRetry) MaxRetries(max int) {
+	if max < 0 {
+		max = 0
+	}
+	w.maxRetries = max
+}
+
+// Retry 不响应 ctx, Request 内部使用 before
+func (w *withRetry) Retry() {
+	_ = w.wait(context.Background())
+}
+
+// use 使用调用方的 WithRetry 等待
+func (w *withRetry) use(custom WithRetry) {
+	custom.MaxRetries(w.maxRetries)
+	w.custom = custom
+}
+
+func (w *withRetry) wait(ctx context.Context) error {
+	if w.custom != nil {
+		return w.waitCustom(ctx)
+	}
+	wait := w.retryAfter
+	if wait <= 0 {
+		wait = w.backoff.Step(w.retries)
+	}
+	w.retries++
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// waitCustom Retry 不响应 ctx, ctx 结束时直接返回, Retry 在后台结束
+func (w *withRetry) waitCustom(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		w.custom.Retry()
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// isNextRetry 根据本次请求的结果判断是否需要重试
+func (w *withRetry) isNextRetry(ctx context.Context, r *Request, req *http.Request, resp *http.Response, err error) bool {
+	if w.attempts > w.maxRetries || ctx.Err() != nil {
+		return false
+	}
+	// 请求体无法重置时不能重试
+	if r.body != nil {
+		if _, ok := r.body.(io.Seeker); !ok {
+			return false
+		}
+	}
+	if !w.policy(req, resp, err) {
+		return false
+	}
+	w.retryAfter = 0
+	if resp != nil {
+		if d, ok := retryAfter(resp); ok {
+			// 等待时间超过上限或 ctx 的截止时间时不再重试, 直接返回服务端的响应
+			if d > w.maxRetryAfter() {
+				return false
+			}
+			if deadline, ok := ctx.Deadline(); ok && time.Now().Add(d).After(deadline) {
+				return false
+			}
+			w.retryAfter = d
+		}
+	}
+	return true
+}
+
+// maxRetryAfter 允许等待的 Retry-After 上限
+func (w *withRetry) maxRetryAfter() time.Duration {
+	if w.backoff.Cap > 0 {
+		return w.backoff.Cap
+	}
+	return DefaultBackoff.Cap
+}
+
+// before 每次请求前调用, 重试时等待退避时间并重置请求体
+func (w *withRetry) before(ctx context.Context, r *Request) error {
+	w.attempts++
+	seeker, seekable := r.body.(io.Seeker)
+	if w.attempts == 1 {
+		// 请求体可能不是从头开始读取, 记录当前位置
+		if seekable {
+			offset, err := seeker.Seek(0, io.SeekCurrent)
+			if err != nil {
+				return err
+			}
+			w.offset = offset
+		}
+		return nil
+	}
+	if err := w.wait(ctx); err != nil {
+		return err
+	}
+	// 重置请求体
+	if seekable {
+		if _, err := seeker.Seek(w.offset, io.SeekStart); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// retryAfter 解析 Retry-After, 支持秒数与 HTTP-date 两种格式
+func retryAfter(resp *http.Response) (time.Duration, bool) {
+	value := resp.Header.Get("Retry-After")
+	if len(value) == 0 {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		d := time.Until(t)
+		if d < 0 {
+			d = 0
+		}
+		return d, true
+	}
+	return 0, false
+}
+
+// readAndCloseResponseBody 丢弃并关闭需要重试的响应, 使连接可以复用
+func readAndCloseResponseBody(resp *http.Response) {
+	if resp == nil || resp.Body == nil {
+		return
+	}
+	const maxBodySlurpSize = 2 << 10
+	defer resp.Body.Close()
+	if resp.ContentLength <= maxBodySlurpSize {
+		_, _ = io.Copy(ioutil.Discard, io.LimitReader(resp.Body, maxBodySlurpSize))
+	}
+}
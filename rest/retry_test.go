@@ -0,0 +1,302 @@
+package rest
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestBackoff_Step(t *testing.T) {
+	b := Backoff{Duration: 10 * time.Millisecond, Factor: 2, Cap: 50 * time.Millisecond}
+	expected := []time.Duration{10, 20, 40, 50, 50}
+	for i, e := range expected {
+		if d := b.Step(i); d != e*time.Millisecond {
+			t.Errorf("step %d: expected %v, got %v", i, e*time.Millisecond, d)
+		}
+	}
+	b.Jitter = 0.5
+	for i := 0; i < 10; i++ {
+		if d := b.Step(0); d < 10*time.Millisecond || d >= 15*time.Millisecond {
+			t.Fatalf("expected jittered step in [10ms, 15ms), got %v", d)
+		}
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	resp := &http.Response{Header: http.Header{}}
+	if _, ok := retryAfter(resp); ok {
+		t.Fatal("expected no Retry-After")
+	}
+	resp.Header.Set("Retry-After", "3")
+	if d, ok := retryAfter(resp); !ok || d != 3*time.Second {
+		t.Fatalf("expected 3s, got %v", d)
+	}
+	resp.Header.Set("Retry-After", time.Now().Add(time.Minute).UTC().Format(http.TimeFormat))
+	if d, ok := retryAfter(resp); !ok || d <= 0 || d > time.Minute {
+		t.Fatalf("expected date based Retry-After, got %v", d)
+	}
+}
+
+func TestDefaultRetryPolicy(t *testing.T) {
+	cases := map[int]bool{
+		http.StatusOK:                  false,
+		http.StatusNotFound:            false,
+		http.StatusTooManyRequests:     true,
+		http.StatusInternalServerError: true,
+		http.StatusNotImplemented:      false,
+		http.StatusBadGateway:          true,
+		http.StatusServiceUnavailable:  true,
+	}
+	get, _ := http.NewRequest("GET", "http://localhost/", nil)
+	for code, expected := range cases {
+		if DefaultRetryPolicy(get, &http.Response{StatusCode: code}, nil) != expected {
+			t.Errorf("status %d: expected retry=%v", code, expected)
+		}
+	}
+	if DefaultRetryPolicy(get, nil, context.Canceled) {
+		t.Error("expected canceled request not to be retried")
+	}
+
+	// 非幂等请求只在连接失败时重试
+	post, _ := http.NewRequest("POST", "http://localhost/", nil)
+	if DefaultRetryPolicy(post, &http.Response{StatusCode: http.StatusServiceUnavailable}, nil) {
+		t.Error("expected POST not to be retried on 503")
+	}
+	if DefaultRetryPolicy(post, nil, io.ErrUnexpectedEOF) {
+		t.Error("expected POST not to be retried after the request may have been sent")
+	}
+	dialErr := &url.Error{Op: "Post", URL: "http://localhost/", Err: &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}}
+	if !DefaultRetryPolicy(post, nil, dialErr) {
+		t.Error("expected POST to be retried on dial error")
+	}
+	post.Header.Set("Idempotency-Key", "1")
+	if !DefaultRetryPolicy(post, &http.Response{StatusCode: http.StatusServiceUnavailable}, nil) {
+		t.Error("expected POST with Idempotency-Key to be retried")
+	}
+}
+
+func newRetryTestClient(t *testing.T, url string, maxRetries int) Interface {
+	client, err := NewRESTClientFor(&Config{
+		Host:       url,
+		MaxRetries: maxRetries,
+		Backoff:    Backoff{Duration: time.Millisecond},
+		ContentConfig: ContentConfig{
+			Codec: NewJsonMarshaler(),
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+func TestRequest_Retry(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		if string(body) != "payload" {
+			t.Errorf("attempt %d: unexpected body %q", atomic.LoadInt32(&calls), body)
+		}
+		if atomic.AddInt32(&calls, 1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	client := newRetryTestClient(t, server.URL, 5)
+	result := client.Put().Path("/").Body([]byte("payload")).Do(context.Background())
+	body, err := result.Raw()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(body) != "ok" || result.StatusCode() != http.StatusOK {
+		t.Fatalf("unexpected result %d %q", result.StatusCode(), body)
+	}
+	if calls != 3 {
+		t.Fatalf("expected 3 attempts, got %d", calls)
+	}
+
+	// 单个请求覆盖重试次数
+	atomic.StoreInt32(&calls, 0)
+	result = client.Put().Path("/").Body([]byte("payload")).MaxRetries(0).Do(context.Background())
+	if result.StatusCode() != http.StatusServiceUnavailable || calls != 1 {
+		t.Fatalf("expected a single attempt, got %d attempts with status %d", calls, result.StatusCode())
+	}
+
+	// POST 默认不重试, 带有 Idempotency-Key 时重试
+	atomic.StoreInt32(&calls, 0)
+	result = client.Post().Path("/").Body([]byte("payload")).Do(context.Background())
+	if result.StatusCode() != http.StatusServiceUnavailable || calls != 1 {
+		t.Fatalf("expected POST not to be retried, got %d attempts with status %d", calls, result.StatusCode())
+	}
+	atomic.StoreInt32(&calls, 0)
+	result = client.Post().Path("/").SetHeader("Idempotency-Key", "1").Body([]byte("payload")).Do(context.Background())
+	if result.StatusCode() != http.StatusOK || calls != 3 {
+		t.Fatalf("expected POST with Idempotency-Key to be retried, got %d attempts with status %d", calls, result.StatusCode())
+	}
+}
+
+func TestRequest_RetryNonSeekableBody(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	client := newRetryTestClient(t, server.URL, 3)
+	body := ioutil.NopCloser(strings.NewReader("payload"))
+	client.Put().Path("/").Body(body).Do(context.Background())
+	if calls != 1 {
+		t.Fatalf("expected non-seekable body not to be retried, got %d attempts", calls)
+	}
+	client.Put().Path("/").Body(bytes.NewReader([]byte("payload"))).Do(context.Background())
+	if calls != 5 {
+		t.Fatalf("expected seekable body to be retried, got %d attempts", calls-1)
+	}
+}
+
+func TestRequest_RetryFileBody(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		if string(body) != "payload" {
+			t.Errorf("attempt %d: unexpected body %q", atomic.LoadInt32(&calls), body)
+		}
+		if atomic.AddInt32(&calls, 1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	file, err := ioutil.TempFile("", "rest-retry")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(file.Name())
+	// 请求体从当前位置开始, 重试时回到该位置而不是文件开头
+	if _, err := file.WriteString("header:payload"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := file.Seek(int64(len("header:")), io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+
+	client := newRetryTestClient(t, server.URL, 2)
+	body, err := client.Put().Path("/").Body(file).DoRaw(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(body) != "ok" || atomic.LoadInt32(&calls) != 3 {
+		t.Fatalf("expected file body to be retried, got %q after %d calls", body, calls)
+	}
+	// 所有尝试结束后关闭文件
+	if _, err := file.Seek(0, io.SeekStart); err == nil {
+		t.Fatal("expected file to be closed after the request")
+	}
+}
+
+func TestRequest_RetryAfterLimit(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.Header().Set("Retry-After", r.URL.Query().Get("after"))
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	client, err := NewRESTClientFor(&Config{
+		Host:       server.URL,
+		MaxRetries: 3,
+		Backoff:    Backoff{Duration: time.Millisecond, Cap: time.Second},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 超过 Backoff.Cap 时不等待, 返回服务端的 429
+	start := time.Now()
+	_, err = client.Get().Path("/").Param("after", "86400").DoRaw(context.Background())
+	if !IsTooManyRequests(err) || atomic.LoadInt32(&calls) != 1 {
+		t.Fatalf("expected 429 without retrying, got %v after %d calls", err, calls)
+	}
+	// 超过 ctx 的截止时间时同样返回 429, 而不是超时错误
+	atomic.StoreInt32(&calls, 0)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = client.Get().Path("/").Param("after", "1").DoRaw(ctx)
+	if !IsTooManyRequests(err) || atomic.LoadInt32(&calls) != 1 {
+		t.Fatalf("expected 429 before the deadline, got %v after %d calls", err, calls)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("expected Retry-After over the limit not to wait, took %v", elapsed)
+	}
+}
+
+func TestWithRetry_Retry(t *testing.T) {
+	retry := newWithRetry(3, Backoff{Duration: 10 * time.Millisecond, Factor: 2}, nil)
+	start := time.Now()
+	retry.Retry()
+	retry.Retry()
+	// 10ms + 20ms
+	if elapsed := time.Since(start); elapsed < 30*time.Millisecond {
+		t.Fatalf("expected Retry to wait for the backoff, took %v", elapsed)
+	}
+}
+
+// countingRetry 记录 WithRetry 的调用
+type countingRetry struct {
+	max     int32
+	retries int32
+}
+
+func (c *countingRetry) MaxRetries(max int) { atomic.StoreInt32(&c.max, int32(max)) }
+func (c *countingRetry) Retry()             { atomic.AddInt32(&c.retries, 1) }
+
+func TestRequest_WithRetry(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	var created []*countingRetry
+	client, err := NewRESTClientFor(&Config{
+		Host:       server.URL,
+		MaxRetries: 2,
+		NewRetry: func() WithRetry {
+			retry := &countingRetry{}
+			created = append(created, retry)
+			return retry
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = client.Get().Path("/").Do(context.Background())
+	if len(created) != 1 || created[0].max != 2 || created[0].retries != 2 || calls != 3 {
+		t.Fatalf("expected Config.NewRetry to drive the waits, got %d retries after %d calls", created[0].retries, calls)
+	}
+
+	// Request.WithRetry 覆盖 Config.NewRetry
+	retry := &countingRetry{}
+	_ = client.Get().Path("/").MaxRetries(1).WithRetry(retry).Do(context.Background())
+	if retry.max != 1 || retry.retries != 1 {
+		t.Fatalf("expected request retry to be used, got max %d retries %d", retry.max, retry.retries)
+	}
+}
@@ -0,0 +1,199 @@
+package rest
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TLSClientConfig 客户端 TLS 配置, 文件与数据同时配置时优先使用数据
+type TLSClientConfig struct {
+	// CAFile/CAData 校验服务端证书的 CA, 为空时使用系统 CA
+	CAFile string
+	CAData []byte
+
+	// CertFile/KeyFile 客户端证书, 文件更新后会在下次握手时重新加载
+	CertFile string
+	CertData []byte
+	KeyFile  string
+	KeyData  []byte `datapolicy:"security-key"`
+
+	// ServerName 用于 SNI 与证书校验的服务端名称
+	ServerName string
+	// Insecure 跳过服务端证书校验, 仅用于测试
+	Insecure bool
+	// MinVersion 最低 TLS 版本, 例如 tls.VersionTLS12
+	MinVersion uint16
+	NextProtos []string
+}
+
+func (c TLSClientConfig) HasCA() bool {
+	return len(c.CAData) > 0 || len(c.CAFile) > 0
+}
+
+func (c TLSClientConfig) HasCertAuth() bool {
+	return (len(c.CertData) > 0 || len(c.CertFile) > 0) && (len(c.KeyData) > 0 || len(c.KeyFile) > 0)
+}
+
+// IsZero 是否没有任何 TLS 配置
+func (c TLSClientConfig) IsZero() bool {
+	return !c.HasCA() && len(c.CertData) == 0 && len(c.CertFile) == 0 && len(c.KeyData) == 0 && len(c.KeyFile) == 0 &&
+		len(c.ServerName) == 0 && !c.Insecure && c.MinVersion == 0 && len(c.NextProtos) == 0
+}
+
+// cacheKey 作为 Transport 缓存 key 的一部分, 证书数据使用摘要
+func (c TLSClientConfig) cacheKey() string {
+	if c.IsZero() {
+		return ""
+	}
+	// 与 TLSConfigFor 的默认值一致
+	minVersion := c.MinVersion
+	if minVersion == 0 {
+		minVersion = tls.VersionTLS12
+	}
+	return fmt.Sprintf("ca:%s/%s,cert:%s/%s,key:%s/%s,serverName:%s,insecure:%t,minVersion:%d,nextProtos:%s",
+		c.CAFile, md5Util(string(c.CAData)),
+		c.CertFile, md5Util(string(c.CertData)),
+		c.KeyFile, md5Util(string(c.KeyData)),
+		c.ServerName, c.Insecure, minVersion, strings.Join(c.NextProtos, ","))
+}
+
+// TLSConfigFor 根据 TLSClientConfig 构造 tls.Config, 没有任何配置时返回 nil
+func TLSConfigFor(c *TLSClientConfig) (*tls.Config, error) {
+	if c == nil || c.IsZero() {
+		return nil, nil
+	}
+	if c.HasCA() && c.Insecure {
+		return nil, errors.New("specifying a root certificates file with the insecure flag is not allowed")
+	}
+	hasCert := len(c.CertData) > 0 || len(c.CertFile) > 0
+	hasKey := len(c.KeyData) > 0 || len(c.KeyFile) > 0
+	if hasCert != hasKey {
+		return nil, errors.New("client certificate and key must be specified together")
+	}
+
+	conf := &tls.Config{
+		MinVersion:         c.MinVersion,
+		InsecureSkipVerify: c.Insecure,
+		ServerName:         c.ServerName,
+		NextProtos:         c.NextProtos,
+	}
+	if conf.MinVersion == 0 {
+		conf.MinVersion = tls.VersionTLS12
+	}
+
+	if c.HasCA() {
+		data := c.CAData
+		if len(data) == 0 {
+			var err error
+			if data, err = ioutil.ReadFile(c.CAFile); err != nil {
+				return nil, err
+			}
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(data) {
+			return nil, fmt.Errorf("unable to load root certificates from %q", c.CAFile)
+		}
+		conf.RootCAs = pool
+	}
+
+	if c.HasCertAuth() {
+		cert := &clientCertificate{
+			certFile: c.CertFile,
+			certData: c.CertData,
+			keyFile:  c.KeyFile,
+			keyData:  c.KeyData,
+		}
+		// 提前加载一次, 配置错误时尽早返回
+		if _, err := cert.load(); err != nil {
+			return nil, err
+		}
+		conf.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			return cert.load()
+		}
+	}
+	return conf, nil
+}
+
+// clientCertificate 客户端证书, 从文件加载时根据修改时间重新加载, 以支持证书轮换
+type clientCertificate struct {
+	certFile string
+	certData []byte
+	keyFile  string
+	keyData  []byte
+
+	mu      sync.Mutex
+	cert    *tls.Certificate
+	modTime time.Time
+}
+
+func (c *clientCertificate) load() (*tls.Certificate, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	modTime, err := c.lastModified()
+	if err != nil {
+		// 文件暂时不可读时 (例如正在轮换) 继续使用旧证书
+		if c.cert != nil {
+			return c.cert, nil
+		}
+		return nil, err
+	}
+	if c.cert != nil && modTime.Equal(c.modTime) {
+		return c.cert, nil
+	}
+
+	certPEM, keyPEM := c.certData, c.keyData
+	if len(certPEM) == 0 {
+		if certPEM, err = ioutil.ReadFile(c.certFile); err != nil {
+			return c.fallback(err)
+		}
+	}
+	if len(keyPEM) == 0 {
+		if keyPEM, err = ioutil.ReadFile(c.keyFile); err != nil {
+			return c.fallback(err)
+		}
+	}
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return c.fallback(err)
+	}
+	c.cert = &cert
+	c.modTime = modTime
+	return c.cert, nil
+}
+
+func (c *clientCertificate) fallback(err error) (*tls.Certificate, error) {
+	if c.cert != nil {
+		return c.cert, nil
+	}
+	return nil, err
+}
+
+// lastModified 证书与私钥文件中较新的修改时间, 使用数据配置时返回零值
+func (c *clientCertificate) lastModified() (time.Time, error) {
+	var files []string
+	if len(c.certData) == 0 {
+		files = append(files, c.certFile)
+	}
+	if len(c.keyData) == 0 {
+		files = append(files, c.keyFile)
+	}
+	var latest time.Time
+	for _, file := range files {
+		info, err := os.Stat(file)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest, nil
+}
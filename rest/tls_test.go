@@ -0,0 +1,201 @@
+package rest
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// newTestCertificate 生成自签名的客户端证书
+func newTestCertificate(t *testing.T, commonName string) (certPEM, keyPEM []byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certPEM = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM = pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	return certPEM, keyPEM
+}
+
+func TestTLSConfigFor_Validation(t *testing.T) {
+	if conf, err := TLSConfigFor(&TLSClientConfig{}); conf != nil || err != nil {
+		t.Fatalf("expected nil config for empty TLS options, got %v %v", conf, err)
+	}
+	if _, err := TLSConfigFor(&TLSClientConfig{CAData: []byte("ca"), Insecure: true}); err == nil {
+		t.Fatal("expected CA with insecure to be rejected")
+	}
+	if _, err := TLSConfigFor(&TLSClientConfig{CertFile: "client.crt"}); err == nil {
+		t.Fatal("expected certificate without key to be rejected")
+	}
+	if _, err := TLSConfigFor(&TLSClientConfig{CAData: []byte("not a certificate")}); err == nil {
+		t.Fatal("expected invalid CA data to be rejected")
+	}
+	conf, err := TLSConfigFor(&TLSClientConfig{ServerName: "example.com", NextProtos: []string{"h2"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conf.ServerName != "example.com" || conf.MinVersion != tls.VersionTLS12 || len(conf.NextProtos) != 1 {
+		t.Fatalf("unexpected tls config %+v", conf)
+	}
+}
+
+func TestTLSClientConfig_MutualTLS(t *testing.T) {
+	certPEM, keyPEM := newTestCertificate(t, "client")
+	clientCAs := x509.NewCertPool()
+	clientCAs.AppendCertsFromPEM(certPEM)
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(r.TLS.PeerCertificates[0].Subject.CommonName))
+	}))
+	server.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: clientCAs}
+	server.StartTLS()
+	defer server.Close()
+
+	caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	client, err := NewRESTClientFor(&Config{
+		Host: server.URL,
+		TLSClientConfig: TLSClientConfig{
+			CAData:   caPEM,
+			CertData: certPEM,
+			KeyData:  keyPEM,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := client.Get().Path("/").DoRaw(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(body) != "client" {
+		t.Fatalf("unexpected client certificate %q", body)
+	}
+
+	// 不信任服务端证书
+	client, _ = NewRESTClientFor(&Config{
+		Host:            server.URL,
+		TLSClientConfig: TLSClientConfig{CertData: certPEM, KeyData: keyPEM},
+	})
+	if _, err := client.Get().Path("/").DoRaw(context.Background()); err == nil {
+		t.Fatal("expected unknown authority error")
+	}
+
+	if _, err := NewRESTClientFor(&Config{
+		Host:            server.URL,
+		Transport:       &http.Transport{},
+		TLSClientConfig: TLSClientConfig{CAData: caPEM},
+	}); err == nil {
+		t.Fatal("expected custom transport with TLS options to be rejected")
+	}
+}
+
+func TestClientCertificate_Reload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rest-tls")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	certFile := filepath.Join(dir, "client.crt")
+	keyFile := filepath.Join(dir, "client.key")
+
+	write := func(commonName string, modTime time.Time) {
+		certPEM, keyPEM := newTestCertificate(t, commonName)
+		for file, data := range map[string][]byte{certFile: certPEM, keyFile: keyPEM} {
+			if err := ioutil.WriteFile(file, data, 0600); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.Chtimes(file, modTime, modTime); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	commonName := func(cert *tls.Certificate) string {
+		leaf, err := x509.ParseCertificate(cert.Certificate[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		return leaf.Subject.CommonName
+	}
+
+	now := time.Now()
+	write("first", now.Add(-time.Minute))
+	conf, err := TLSConfigFor(&TLSClientConfig{CertFile: certFile, KeyFile: keyFile})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := conf.GetClientCertificate(nil)
+	if err != nil || commonName(cert) != "first" {
+		t.Fatalf("unexpected certificate %v", err)
+	}
+
+	write("second", now)
+	cert, err = conf.GetClientCertificate(nil)
+	if err != nil || commonName(cert) != "second" {
+		t.Fatalf("expected rotated certificate to be reloaded, got %v", err)
+	}
+
+	// 文件被删除时继续使用已加载的证书
+	_ = os.Remove(certFile)
+	cert, err = conf.GetClientCertificate(nil)
+	if err != nil || commonName(cert) != "second" {
+		t.Fatalf("expected previous certificate to be kept, got %v", err)
+	}
+}
+
+func TestTransportCache_TLSKey(t *testing.T) {
+	pool := newTransportPool()
+	a := pool.Get(&TransportConfig{TLS: TLSClientConfig{ServerName: "a"}})
+	b := pool.Get(&TransportConfig{TLS: TLSClientConfig{ServerName: "b"}})
+	c := pool.Get(&TransportConfig{TLS: TLSClientConfig{ServerName: "a"}})
+	if a == b || a != c {
+		t.Fatal("expected TLS options to be part of the transport cache key")
+	}
+	d := pool.Get(&TransportConfig{TLS: TLSClientConfig{ServerName: "a", MinVersion: tls.VersionTLS12}})
+	if a != d {
+		t.Fatal("expected default MinVersion to share a transport")
+	}
+}
+
+func TestNewDefaultTransport_InvalidTLS(t *testing.T) {
+	config := &TransportConfig{TLS: TLSClientConfig{CAData: []byte("not a certificate")}}
+	if _, err := NewDefaultTransportE(config); err == nil {
+		t.Fatal("expected invalid CA data to be rejected")
+	}
+	// 保持原有签名, 错误在请求时返回
+	req, _ := http.NewRequest("GET", "https://localhost/", nil)
+	if _, err := NewDefaultTransport(config).RoundTrip(req); err == nil {
+		t.Fatal("expected request to fail with the TLS error")
+	}
+	if newTransportPool().Get(config) != nil {
+		t.Fatal("expected pool to return nil for invalid TLS options")
+	}
+}
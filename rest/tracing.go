@@ -0,0 +1,96 @@
+package rest
+
+import (
+	"context"
+	"net/http"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "github.com/ultraman/go-common/rest"
+
+// retryCountKey 重试次数, semconv v1.4.0 中没有对应的属性
+const retryCountKey = attribute.Key("http.retry_count")
+
+// hedgeAttemptKey 对冲请求的序号, 0 为第一个请求
+const hedgeAttemptKey = attribute.Key("http.hedge_attempt")
+
+// defaultTracer 使用全局 TracerProvider, otel.SetTracerProvider 在创建客户端之后调用也会生效
+func defaultTracer() trace.Tracer {
+	return otel.GetTracerProvider().Tracer(tracerName)
+}
+
+// defaultPropagator W3C traceparent 与 tracestate
+func defaultPropagator() propagation.TextMapPropagator {
+	return propagation.TraceContext{}
+}
+
+// startSpan 为 Do/Stream 创建客户端 span, 父 span 来自 ctx
+func (r *Request) startSpan(ctx context.Context) (context.Context, trace.Span) {
+	tracer := r.c.tracer
+	if tracer == nil {
+		tracer = defaultTracer()
+	}
+	u := *r.URL()
+	u.RawQuery = ""
+	attrs := []attribute.KeyValue{
+		semconv.HTTPMethodKey.String(r.verb),
+		semconv.HTTPURLKey.String(u.String()),
+		semconv.NetPeerNameKey.String(u.Hostname()),
+	}
+	// 没有路径模板时 span 名称只使用请求方法
+	name := r.verb
+	if route := r.Route(); route != UntemplatedRoute {
+		name += " " + route
+		attrs = append(attrs, semconv.HTTPRouteKey.String(route))
+	}
+	return tracer.Start(ctx, name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attrs...),
+	)
+}
+
+// startHedgeSpan 对冲时每个请求创建一个子 span, 服务端可以区分同时发出的重复请求
+func (r *Request) startHedgeSpan(ctx context.Context, index int) (context.Context, trace.Span) {
+	tracer := r.c.tracer
+	if tracer == nil {
+		tracer = defaultTracer()
+	}
+	return tracer.Start(ctx, r.verb+" hedge",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(hedgeAttemptKey.Int(index)),
+	)
+}
+
+// injectTrace 将当前 span 写入请求头, 每次重试使用同一个 span, 对冲请求使用各自的子 span
+func (r *Request) injectTrace(ctx context.Context, req *http.Request) {
+	propagator := r.c.propagator
+	if propagator == nil {
+		propagator = defaultPropagator()
+	}
+	propagator.Inject(ctx, propagation.HeaderCarrier(req.Header))
+}
+
+// traceRetry 记录一次重试
+func traceRetry(ctx context.Context, attempt int) {
+	span := trace.SpanFromContext(ctx)
+	span.AddEvent("retry", trace.WithAttributes(attribute.Int("attempt", attempt)))
+	span.SetAttributes(retryCountKey.Int(attempt))
+}
+
+// endSpan 记录最终的状态码与 Result.Error()
+func endSpan(span trace.Span, code int, err error) {
+	if code > 0 {
+		span.SetAttributes(semconv.HTTPStatusCodeKey.Int(code))
+	}
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -0,0 +1,179 @@
+package rest
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func spanAttributes(span sdktrace.ReadOnlySpan) map[attribute.Key]attribute.Value {
+	attrs := make(map[attribute.Key]attribute.Value)
+	for _, kv := range span.Attributes() {
+		attrs[kv.Key] = kv.Value
+	}
+	return attrs
+}
+
+func TestTracing(t *testing.T) {
+	var calls int32
+	var traceparents []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparents = append(traceparents, r.Header.Get("traceparent"))
+		if r.URL.Path == "/missing" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	client, err := NewRESTClientFor(&Config{
+		Host:           server.URL,
+		MaxRetries:     1,
+		Backoff:        Backoff{Duration: time.Millisecond},
+		TracerProvider: provider,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, parent := provider.Tracer("test").Start(context.Background(), "parent")
+	if err := client.Get().Path("/users/{id}").PathParam("id", "1").Do(ctx).Error(); err != nil {
+		t.Fatal(err)
+	}
+	parent.End()
+
+	spans := recorder.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("expected 2 spans, got %d", len(spans))
+	}
+	span := spans[0]
+	if span.Name() != "GET /users/{id}" || span.SpanKind() != trace.SpanKindClient {
+		t.Errorf("unexpected span %s %s", span.Name(), span.SpanKind())
+	}
+	if span.Parent().SpanID() != parent.SpanContext().SpanID() {
+		t.Error("expected span to be a child of the ctx span")
+	}
+	attrs := spanAttributes(span)
+	if attrs["http.route"].AsString() != "/users/{id}" || attrs["http.status_code"].AsInt64() != 200 || attrs["http.retry_count"].AsInt64() != 1 {
+		t.Errorf("unexpected attributes %v", attrs)
+	}
+	if span.Status().Code == codes.Error {
+		t.Errorf("unexpected error status %v", span.Status())
+	}
+	expected := "00-" + span.SpanContext().TraceID().String() + "-" + span.SpanContext().SpanID().String() + "-01"
+	if len(traceparents) != 2 || traceparents[0] != expected || traceparents[1] != expected {
+		t.Errorf("expected traceparent %s on every attempt, got %v", expected, traceparents)
+	}
+
+	if err := client.Get().Path("/missing").Do(context.Background()).Error(); err == nil {
+		t.Fatal("expected not found error")
+	}
+	spans = recorder.Ended()
+	span = spans[len(spans)-1]
+	if span.Status().Code != codes.Error || spanAttributes(span)["http.status_code"].AsInt64() != 404 {
+		t.Errorf("expected error status for 404, got %v", span.Status())
+	}
+	if span.Parent().IsValid() {
+		t.Error("expected root span without parent")
+	}
+}
+
+func TestTracing_Stream(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("chunk"))
+	}))
+	defer server.Close()
+
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	client, err := NewRESTClientFor(&Config{Host: server.URL, TracerProvider: provider})
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := client.Get().Path("/export").Stream(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recorder.Ended()) != 0 {
+		t.Fatal("span must stay open until the stream is closed")
+	}
+	_, _ = ioutil.ReadAll(body)
+	_ = body.Close()
+	if spans := recorder.Ended(); len(spans) != 1 || spans[0].Name() != "GET" {
+		t.Fatalf("unexpected spans %v", spans)
+	}
+}
+
+func TestTracing_Hedge(t *testing.T) {
+	var mu sync.Mutex
+	var traceparents []string
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		traceparents = append(traceparents, r.Header.Get("traceparent"))
+		mu.Unlock()
+		if atomic.AddInt32(&calls, 1) == 1 {
+			<-r.Context().Done()
+			return
+		}
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	client, err := NewRESTClientFor(&Config{Host: server.URL, TracerProvider: provider})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Get().Path("/").Hedge(HedgePolicy{Delay: 20 * time.Millisecond}).Do(context.Background()).Error(); err != nil {
+		t.Fatal(err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	// 每个请求的 traceparent 属于同一个 trace, 但使用不同的 span
+	if len(traceparents) != 2 || traceparents[0] == traceparents[1] || traceparents[0][:35] != traceparents[1][:35] {
+		t.Fatalf("expected distinct traceparents in the same trace, got %v", traceparents)
+	}
+	// 被取消的请求在后台结束 span
+	deadline := time.Now().Add(time.Second)
+	for len(recorder.Ended()) < 3 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	var root sdktrace.ReadOnlySpan
+	var hedges []sdktrace.ReadOnlySpan
+	for _, span := range recorder.Ended() {
+		if span.Name() == "GET" {
+			root = span
+		} else if span.Name() == "GET hedge" {
+			hedges = append(hedges, span)
+		}
+	}
+	if root == nil || len(hedges) != 2 {
+		t.Fatalf("expected a child span per attempt, got %d", len(hedges))
+	}
+	for _, span := range hedges {
+		if span.Parent().SpanID() != root.SpanContext().SpanID() {
+			t.Errorf("expected hedge span %v to be a child of the request span", span.SpanContext().SpanID())
+		}
+	}
+}
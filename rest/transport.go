@@ -2,9 +2,14 @@ package rest
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
 	"net"
 	"net/http"
 	"net/url"
+	"strings"
+	"sync"
 	"time"
 )
 
@@ -12,18 +17,41 @@ type TransportConfig struct {
 	Username string
 	Password string
 
+	// BearerTokenFile 不为空时定期重新读取文件中的 token, 优先于 BearerToken
+	BearerToken     string
+	BearerTokenFile string
+
 	UserAgent string
 
 	Transport     http.RoundTripper
 	WrapTransport WrapperFunc
 	Dial          func(ctx context.Context, network, address string) (net.Conn, error)
 	Proxy         func(*http.Request) (*url.URL, error)
+
+	// DialTimeout 建立连接超时时间, 默认 3s
+	DialTimeout time.Duration
+	// IdleConnTimeout 空闲连接保留时间, 默认 90s
+	IdleConnTimeout time.Duration
+
+	TLS TLSClientConfig
+
+	// Debug 调试日志, 位于最内层, 记录认证等 WrapperFunc 修改后的请求
+	Debug DebugConfig
+
+	// AuthProvider 包装在 WrapTransport 之外
+	AuthProvider AuthProvider
+	// loginAuthProvider 由 AuthConfig 构造的 AuthProvider 在 HTTPWrappersForConfig 中登录一次
+	loginAuthProvider bool
 }
 
 func (c *TransportConfig) HasBasicAuth() bool {
 	return len(c.Username) != 0
 }
 
+func (c *TransportConfig) HasTokenAuth() bool {
+	return len(c.BearerToken) != 0 || len(c.BearerTokenFile) != 0
+}
+
 func (c *TransportConfig) Wrap(fn WrapperFunc) {
 	c.WrapTransport = Wrappers(c.WrapTransport, fn)
 }
@@ -54,11 +82,18 @@ func NewTransport(config *TransportConfig) (http.RoundTripper, error) {
 	var (
 		rt http.RoundTripper
 	)
-	// 可以考虑cache transport
+	// 相同配置的 Transport 从缓存中获取, 共享连接池
 	if config.Transport != nil {
+		if !config.TLS.IsZero() {
+			return nil, errors.New("using a custom transport with TLS certificate options is not allowed")
+		}
 		rt = config.Transport
 	} else {
-		rt = NewDefaultTransport(config)
+		transport, err := TransportCache.GetE(config)
+		if err != nil {
+			return nil, err
+		}
+		rt = transport
 	}
 
 	return HTTPWrappersForConfig(config, rt)
@@ -72,35 +107,127 @@ func TransportFor(config *Config) (http.RoundTripper, error) {
 	return NewTransport(conf)
 }
 
+// transportSetter 需要发起请求的 AuthProvider, 例如获取 OAuth2 token, 使用客户端的 Transport
+type transportSetter interface {
+	setTransport(rt http.RoundTripper)
+}
+
+// HTTPWrappersForConfig 按配置包装 rt, 所有配置检查通过后才会调用 AuthProvider.Login
 func HTTPWrappersForConfig(config *TransportConfig, rt http.RoundTripper) (http.RoundTripper, error) {
+	if config.HasBasicAuth() && config.HasTokenAuth() {
+		return nil, errors.New("username/password or bearer token may be set, but not both")
+	}
+	if setter, ok := config.AuthProvider.(transportSetter); ok {
+		setter.setTransport(rt)
+	}
+	if config.Debug.Level > DebugNone {
+		rt = NewDebuggingRoundTripper(rt, config.Debug)
+	}
 	if config.WrapTransport != nil {
 		rt = config.WrapTransport(rt)
 	}
+	if config.AuthProvider != nil {
+		rt = config.AuthProvider.WrapTransport(rt)
+	}
 	if len(config.UserAgent) > 0 {
 		rt = NewUserAgentRoundTripper(config.UserAgent, rt)
 	}
 	switch {
 	case config.HasBasicAuth():
 		rt = NewBasicAuthRoundTripper(config.Username, config.Password, rt)
+	case config.HasTokenAuth():
+		var err error
+		rt, err = NewBearerAuthWithRefreshRoundTripper(config.BearerToken, config.BearerTokenFile, rt)
+		if err != nil {
+			return nil, err
+		}
+	}
+	// 登录可能需要发起请求, 放在最后避免配置错误时访问网络
+	if config.AuthProvider != nil && config.loginAuthProvider {
+		if err := config.AuthProvider.Login(); err != nil {
+			return nil, err
+		}
 	}
 
 	return rt, nil
 }
 
+// NewDefaultTransport TLS 配置错误时返回的 RoundTripper 在每次请求时返回该错误,
+// 需要在构造时检查错误时使用 NewDefaultTransportE
 func NewDefaultTransport(config *TransportConfig) http.RoundTripper {
+	rt, err := NewDefaultTransportE(config)
+	if err != nil {
+		return &errorRoundTripper{err: err}
+	}
+	return rt
+}
+
+// NewDefaultTransportE 与 NewDefaultTransport 相同, 返回 TLS 配置错误
+func NewDefaultTransportE(config *TransportConfig) (http.RoundTripper, error) {
+	return newHTTPTransport(config)
+}
+
+// errorRoundTripper 所有请求都返回 err
+type errorRoundTripper struct {
+	err error
+}
+
+func (rt *errorRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		_ = req.Body.Close()
+	}
+	return nil, rt.err
+}
+
+func newHTTPTransport(config *TransportConfig) (*http.Transport, error) {
+	tlsConfig, err := TLSConfigFor(&config.TLS)
+	if err != nil {
+		return nil, err
+	}
+	dialTimeout, idleConnTimeout := transportTimeouts(config)
+	dial := config.Dial
+	if dial == nil {
+		dial = (&net.Dialer{
+			Timeout: dialTimeout,
+		}).DialContext
+	}
 	return &http.Transport{
-		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
-			c, err := net.DialTimeout(network, addr, time.Second*3)
-			if err != nil {
-				return nil, err
-			}
-			return c, nil
-		},
+		Proxy:                 config.Proxy,
+		DialContext:           dial,
+		TLSClientConfig:       tlsConfig,
+		ForceAttemptHTTP2:     tlsConfig != nil && hasProto(tlsConfig.NextProtos, "h2"),
 		MaxIdleConnsPerHost:   128,
 		MaxIdleConns:          2048,
-		IdleConnTimeout:       time.Second * 90,
+		IdleConnTimeout:       idleConnTimeout,
 		ExpectContinueTimeout: 5 * time.Second,
+	}, nil
+}
+
+const (
+	defaultDialTimeout     = time.Second * 3
+	defaultIdleConnTimeout = time.Second * 90
+)
+
+// transportTimeouts 填充默认值, 构造 Transport 与缓存 key 使用相同的值
+func transportTimeouts(config *TransportConfig) (dialTimeout, idleConnTimeout time.Duration) {
+	dialTimeout = config.DialTimeout
+	if dialTimeout <= 0 {
+		dialTimeout = defaultDialTimeout
+	}
+	idleConnTimeout = config.IdleConnTimeout
+	if idleConnTimeout <= 0 {
+		idleConnTimeout = defaultIdleConnTimeout
 	}
+	return dialTimeout, idleConnTimeout
+}
+
+func hasProto(protos []string, proto string) bool {
+	for _, p := range protos {
+		if p == proto {
+			return true
+		}
+	}
+	return false
 }
 
 type userAgentRoundTripper struct {
@@ -138,3 +265,80 @@ func (rt *basicAuthRoundTripper) RoundTrip(req *http.Request) (*http.Response, e
 	req.SetBasicAuth(rt.username, rt.password)
 	return rt.rt.RoundTrip(req)
 }
+
+// TokenFileRefreshPeriod token 文件的重新读取周期
+var TokenFileRefreshPeriod = time.Minute
+
+type bearerAuthRoundTripper struct {
+	bearer string `datapolicy:"token"`
+	source *tokenFileSource
+	rt     http.RoundTripper
+}
+
+func NewBearerAuthRoundTripper(bearer string, rt http.RoundTripper) http.RoundTripper {
+	return &bearerAuthRoundTripper{bearer: bearer, rt: rt}
+}
+
+// NewBearerAuthWithRefreshRoundTripper tokenFile 不为空时定期从文件读取 token,
+// 以支持 token 轮换, 读取失败时继续使用上一次的 token
+func NewBearerAuthWithRefreshRoundTripper(bearer string, tokenFile string, rt http.RoundTripper) (http.RoundTripper, error) {
+	if len(tokenFile) == 0 {
+		return NewBearerAuthRoundTripper(bearer, rt), nil
+	}
+	source := newTokenFileSource(tokenFile, TokenFileRefreshPeriod)
+	if _, err := source.Token(); err != nil {
+		return nil, err
+	}
+	return &bearerAuthRoundTripper{bearer: bearer, source: source, rt: rt}, nil
+}
+
+func (rt *bearerAuthRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	if len(req.Header.Get("Authorization")) != 0 {
+		return rt.rt.RoundTrip(req)
+	}
+	token := rt.bearer
+	if rt.source != nil {
+		if refreshed, err := rt.source.Token(); err == nil {
+			token = refreshed
+		}
+	}
+	req = CloneRequest(req)
+	req.Header.Set("Authorization", "Bearer "+token)
+	return rt.rt.RoundTrip(req)
+}
+
+type tokenFileSource struct {
+	path   string
+	period time.Duration
+
+	mu     sync.Mutex
+	token  string
+	expiry time.Time
+}
+
+func newTokenFileSource(path string, period time.Duration) *tokenFileSource {
+	return &tokenFileSource{path: path, period: period}
+}
+
+func (s *tokenFileSource) Token() (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	if len(s.token) != 0 && now.Before(s.expiry) {
+		return s.token, nil
+	}
+	data, err := ioutil.ReadFile(s.path)
+	if err == nil {
+		if token := strings.TrimSpace(string(data)); len(token) != 0 {
+			s.token = token
+			s.expiry = now.Add(s.period)
+			return s.token, nil
+		}
+		err = fmt.Errorf("read empty token from file %q", s.path)
+	}
+	// 读取失败时继续使用旧 token
+	if len(s.token) != 0 {
+		return s.token, nil
+	}
+	return "", err
+}
@@ -2,8 +2,11 @@ package rest
 
 import (
 	"fmt"
+	"io/ioutil"
 	"net/http"
+	"os"
 	"testing"
+	"time"
 )
 
 func TestWrappers(t *testing.T) {
@@ -14,3 +17,88 @@ func TestWrappers(t *testing.T) {
 		return rt
 	})
 }
+
+type recordRoundTripper struct {
+	req *http.Request
+}
+
+func (rt *recordRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	rt.req = req
+	return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody, Request: req}, nil
+}
+
+func TestBearerAuthRoundTripper(t *testing.T) {
+	record := &recordRoundTripper{}
+	rt := NewBearerAuthRoundTripper("token", record)
+	req, _ := http.NewRequest("GET", "http://localhost", nil)
+	if _, err := rt.RoundTrip(req); err != nil {
+		t.Fatal(err)
+	}
+	if auth := record.req.Header.Get("Authorization"); auth != "Bearer token" {
+		t.Fatalf("unexpected Authorization %q", auth)
+	}
+	if len(req.Header.Get("Authorization")) != 0 {
+		t.Fatal("original request should not be modified")
+	}
+	req.Header.Set("Authorization", "Basic xxx")
+	_, _ = rt.RoundTrip(req)
+	if auth := record.req.Header.Get("Authorization"); auth != "Basic xxx" {
+		t.Fatalf("expected existing Authorization to be kept, got %q", auth)
+	}
+}
+
+func TestBearerAuthRoundTripper_TokenFile(t *testing.T) {
+	file, err := ioutil.TempFile("", "token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(file.Name())
+	_, _ = file.WriteString("first\n")
+	_ = file.Close()
+
+	period := TokenFileRefreshPeriod
+	TokenFileRefreshPeriod = 10 * time.Millisecond
+	defer func() {
+		TokenFileRefreshPeriod = period
+	}()
+
+	record := &recordRoundTripper{}
+	rt, err := NewBearerAuthWithRefreshRoundTripper("", file.Name(), record)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, _ := http.NewRequest("GET", "http://localhost", nil)
+	_, _ = rt.RoundTrip(req)
+	if auth := record.req.Header.Get("Authorization"); auth != "Bearer first" {
+		t.Fatalf("unexpected Authorization %q", auth)
+	}
+
+	_ = ioutil.WriteFile(file.Name(), []byte("second"), 0600)
+	time.Sleep(20 * time.Millisecond)
+	_, _ = rt.RoundTrip(req)
+	if auth := record.req.Header.Get("Authorization"); auth != "Bearer second" {
+		t.Fatalf("expected rotated token, got %q", auth)
+	}
+
+	// 文件不可读时继续使用旧 token
+	_ = os.Remove(file.Name())
+	time.Sleep(20 * time.Millisecond)
+	_, _ = rt.RoundTrip(req)
+	if auth := record.req.Header.Get("Authorization"); auth != "Bearer second" {
+		t.Fatalf("expected previous token to be kept, got %q", auth)
+	}
+
+	if _, err := NewBearerAuthWithRefreshRoundTripper("", file.Name(), record); err == nil {
+		t.Fatal("expected missing token file to be rejected")
+	}
+}
+
+func TestHTTPWrappersForConfig_AuthValidation(t *testing.T) {
+	_, err := NewRESTClientFor(&Config{Host: "http://localhost", Username: "user", Password: "pass", BearerToken: "token"})
+	if err == nil {
+		t.Fatal("expected basic auth with bearer token to be rejected")
+	}
+	if _, err := NewRESTClientFor(&Config{Host: "http://localhost", BearerToken: "token"}); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,289 @@
+package rest
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultWatchRetry Server-Sent Events 断开后重连前的等待时间, 服务端可以通过 retry 字段修改
+var DefaultWatchRetry = 3 * time.Second
+
+// maxWatchRetry 连续重连失败时退避时间的上限
+const maxWatchRetry = time.Minute
+
+// Event 事件流中的一帧
+type Event struct {
+	// ID, Type, Retry 仅用于 Server-Sent Events
+	ID    string
+	Type  string
+	Data  []byte
+	Retry time.Duration
+	// Err 连接失败或读取出错, 之后 ResultChan 会被关闭
+	Err error
+
+	codec Marshaler
+}
+
+// Into 使用客户端的 Marshaler 解码 Data
+func (e Event) Into(obj interface{}) error {
+	if e.Err != nil {
+		return e.Err
+	}
+	if e.codec == nil {
+		return errors.New("serializer for event doesn't exist")
+	}
+	return e.codec.Unmarshal(e.Data, obj)
+}
+
+// WatchInterface 持续接收事件, Stop 或 ctx 取消后 ResultChan 会被关闭
+type WatchInterface interface {
+	ResultChan() <-chan Event
+	Stop()
+}
+
+// Watch 消费 newline-delimited JSON 或 text/event-stream 接口
+// Server-Sent Events 在连接断开后使用 Last-Event-ID 重新连接, 网络错误时按指数退避重试,
+// 默认一直重连直到 ctx 取消, WatchRetries 限制连续重连的次数, 不使用 MaxRetries
+// 注意 Request.Timeout 会限制每个连接的总时长
+func (r *Request) Watch(ctx context.Context) (WatchInterface, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	body, contentType, err := r.watchStream(ctx)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	w := &streamWatcher{
+		r:      r,
+		ctx:    ctx,
+		cancel: cancel,
+		result: make(chan Event),
+		retry:  DefaultWatchRetry,
+	}
+	go w.receive(body, contentType)
+	return w, nil
+}
+
+// WatchRetries 限制 Watch 断开后连续重连的次数, 0 表示一直重连直到 ctx 取消
+func (r *Request) WatchRetries(max int) *Request {
+	if r.err != nil {
+		return r
+	}
+	if max < 0 {
+		max = 0
+	}
+	r.watchRetries = max
+	return r
+}
+
+// watchStream 与 Stream 相同, 额外返回响应的 Content-Type
+func (r *Request) watchStream(ctx context.Context) (io.ReadCloser, string, error) {
+	body, header, err := r.stream(ctx)
+	if err != nil {
+		return nil, "", err
+	}
+	return body, header.Get("Content-Type"), nil
+}
+
+type streamWatcher struct {
+	r      *Request
+	ctx    context.Context
+	cancel context.CancelFunc
+	result chan Event
+
+	mu          sync.Mutex
+	body        io.ReadCloser
+	lastEventID string
+	retry       time.Duration
+	codec       Marshaler
+}
+
+func (w *streamWatcher) ResultChan() <-chan Event {
+	return w.result
+}
+
+func (w *streamWatcher) Stop() {
+	w.cancel()
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.body != nil {
+		_ = w.body.Close()
+	}
+}
+
+func (w *streamWatcher) setBody(body io.ReadCloser) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.ctx.Err() != nil {
+		_ = body.Close()
+		return false
+	}
+	w.body = body
+	return true
+}
+
+func (w *streamWatcher) send(event Event) bool {
+	event.codec = w.codec
+	select {
+	case w.result <- event:
+		return true
+	case <-w.ctx.Done():
+		return false
+	}
+}
+
+func (w *streamWatcher) receive(body io.ReadCloser, contentType string) {
+	defer close(w.result)
+	defer w.cancel()
+
+	for {
+		if !w.setBody(body) {
+			return
+		}
+		w.codec = w.r.codecFor(contentType)
+		var err error
+		if mediaType(contentType) == "text/event-stream" {
+			err = w.decodeEvents(body)
+		} else {
+			err = w.decodeLines(body)
+		}
+		_ = body.Close()
+		if w.ctx.Err() != nil {
+			return
+		}
+		if mediaType(contentType) != "text/event-stream" {
+			if err != nil && err != io.EOF {
+				w.send(Event{Err: err})
+			}
+			return
+		}
+		// Server-Sent Events 连接断开后重连
+		if body, contentType, err = w.reconnect(); err != nil {
+			if w.ctx.Err() == nil {
+				w.send(Event{Err: err})
+			}
+			return
+		}
+	}
+}
+
+// reconnect 等待 retry 后使用 Last-Event-ID 重新连接, 网络错误时从 retry 开始指数退避,
+// 连续失败超过 MaxRetries 次或非 2xx 响应时返回错误
+func (w *streamWatcher) reconnect() (io.ReadCloser, string, error) {
+	backoff := Backoff{Duration: w.retry, Factor: 2, Jitter: 0.2, Cap: maxWatchRetry}
+	if backoff.Cap < w.retry {
+		backoff.Cap = w.retry
+	}
+	for attempt := 0; ; attempt++ {
+		wait := w.retry
+		if attempt > 0 {
+			wait = backoff.Step(attempt - 1)
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-w.ctx.Done():
+			timer.Stop()
+			return nil, "", w.ctx.Err()
+		}
+		body, contentType, err := w.reconnectRequest().watchStream(w.ctx)
+		if err == nil {
+			return body, contentType, nil
+		}
+		if StatusCodeForError(err) != 0 || w.ctx.Err() != nil || (w.r.watchRetries > 0 && attempt+1 >= w.r.watchRetries) {
+			return nil, "", err
+		}
+	}
+}
+
+// reconnectRequest 每次重连使用独立的请求头, 不修改调用方的 Request, 重试由 reconnect 负责
+func (w *streamWatcher) reconnectRequest() *Request {
+	r := *w.r
+	r.headers = CloneHeader(w.r.headers)
+	if len(w.lastEventID) > 0 {
+		r.headers.Set("Last-Event-ID", w.lastEventID)
+	}
+	r.maxRetries = 0
+	return &r
+}
+
+// decodeLines newline-delimited JSON, 每一行为一个事件
+func (w *streamWatcher) decodeLines(body io.Reader) error {
+	reader := bufio.NewReader(body)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if line = bytes.TrimSpace(line); len(line) > 0 {
+			if !w.send(Event{Data: line}) {
+				return nil
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// decodeEvents 按照 https://html.spec.whatwg.org/multipage/server-sent-events.html 解析事件
+func (w *streamWatcher) decodeEvents(body io.Reader) error {
+	reader := bufio.NewReader(body)
+	var (
+		eventType string
+		data      []string
+		hasData   bool
+	)
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil && len(line) == 0 {
+			return err
+		}
+		line = strings.TrimRight(line, "\r\n")
+
+		if len(line) == 0 {
+			// 空行分发事件
+			if hasData {
+				event := Event{
+					ID:    w.lastEventID,
+					Type:  eventType,
+					Data:  []byte(strings.Join(data, "\n")),
+					Retry: w.retry,
+				}
+				if len(event.Type) == 0 {
+					event.Type = "message"
+				}
+				if !w.send(event) {
+					return nil
+				}
+			}
+			eventType, data, hasData = "", nil, false
+		} else if !strings.HasPrefix(line, ":") {
+			field, value := line, ""
+			if i := strings.IndexByte(line, ':'); i >= 0 {
+				field, value = line[:i], strings.TrimPrefix(line[i+1:], " ")
+			}
+			switch field {
+			case "event":
+				eventType = value
+			case "data":
+				data = append(data, value)
+				hasData = true
+			case "id":
+				if !strings.ContainsRune(value, 0) {
+					w.lastEventID = value
+				}
+			case "retry":
+				if ms, err := strconv.Atoi(value); err == nil && ms >= 0 {
+					w.retry = time.Duration(ms) * time.Millisecond
+				}
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
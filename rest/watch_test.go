@@ -0,0 +1,217 @@
+package rest
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type watchEvent struct {
+	Name string `json:"name"`
+}
+
+func TestRequest_WatchNDJSON(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		for _, name := range []string{"a", "b", "c"} {
+			_, _ = fmt.Fprintf(w, "{\"name\":%q}\n\n", name)
+			w.(http.Flusher).Flush()
+		}
+	}))
+	defer server.Close()
+
+	client, _ := NewRESTClientFor(&Config{Host: server.URL, ContentConfig: ContentConfig{Codec: NewJsonMarshaler()}})
+	watcher, err := client.Get().Path("/").Watch(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names string
+	for event := range watcher.ResultChan() {
+		obj := &watchEvent{}
+		if err := event.Into(obj); err != nil {
+			t.Fatal(err)
+		}
+		names += obj.Name
+	}
+	if names != "abc" {
+		t.Fatalf("unexpected events %q", names)
+	}
+}
+
+func TestRequest_WatchServerSentEvents(t *testing.T) {
+	var connections int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		switch atomic.AddInt32(&connections, 1) {
+		case 1:
+			_, _ = fmt.Fprint(w, ": comment\nretry: 10\n\nevent: created\nid: 1\ndata: {\"name\":\n")
+			_, _ = fmt.Fprint(w, "data: \"a\"}\n\n")
+		case 2:
+			if r.Header.Get("Last-Event-ID") != "1" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			_, _ = fmt.Fprint(w, "id: 2\r\ndata: {\"name\":\"b\"}\r\n\r\n")
+		default:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	client, _ := NewRESTClientFor(&Config{Host: server.URL, ContentConfig: ContentConfig{Codec: NewJsonMarshaler()}})
+	watcher, err := client.Get().Path("/").Watch(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var events []Event
+	for event := range watcher.ResultChan() {
+		events = append(events, event)
+	}
+	if len(events) != 3 {
+		t.Fatalf("expected 2 events and an error, got %v", events)
+	}
+	first := &watchEvent{}
+	if err := events[0].Into(first); err != nil || first.Name != "a" {
+		t.Fatalf("unexpected first event %+v %v", events[0], err)
+	}
+	if events[0].ID != "1" || events[0].Type != "created" || events[0].Retry != 10*time.Millisecond {
+		t.Fatalf("unexpected first event fields %+v", events[0])
+	}
+	if events[1].ID != "2" || events[1].Type != "message" || string(events[1].Data) != `{"name":"b"}` {
+		t.Fatalf("unexpected second event %+v", events[1])
+	}
+	// 第三次连接返回非 2xx, 不再重连
+	if !IsServerError(events[2].Err) || connections != 3 {
+		t.Fatalf("expected reconnect to stop on a status error, got %v after %d connections", events[2].Err, connections)
+	}
+}
+
+func TestRequest_WatchCancel(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		for i := 0; ; i++ {
+			if _, err := fmt.Fprintf(w, "data: %d\n\n", i); err != nil {
+				return
+			}
+			w.(http.Flusher).Flush()
+			select {
+			case <-time.After(5 * time.Millisecond):
+			case <-r.Context().Done():
+				return
+			}
+		}
+	}))
+	defer server.Close()
+
+	client, _ := NewRESTClientFor(&Config{Host: server.URL})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	watcher, err := client.Get().Path("/").Watch(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-watcher.ResultChan()
+	cancel()
+	waitClosed(t, watcher.ResultChan())
+
+	watcher, err = client.Get().Path("/").Watch(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-watcher.ResultChan()
+	watcher.Stop()
+	waitClosed(t, watcher.ResultChan())
+}
+
+func waitClosed(t *testing.T, ch <-chan Event) {
+	timeout := time.After(time.Second)
+	for {
+		select {
+		case event, ok := <-ch:
+			if !ok {
+				return
+			}
+			if event.Err != nil {
+				t.Fatalf("unexpected error after stop %v", event.Err)
+			}
+		case <-timeout:
+			t.Fatal("expected result channel to be closed")
+		}
+	}
+}
+
+func TestRequest_WatchReconnectBackoff(t *testing.T) {
+	var connections int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&connections, 1) > 1 {
+			// 模拟网络错误
+			conn, _, _ := w.(http.Hijacker).Hijack()
+			_ = conn.Close()
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = fmt.Fprint(w, "retry: 5\nid: 1\ndata: a\n\n")
+	}))
+	defer server.Close()
+
+	// 不复用连接, 避免 net/http 在复用的连接上自动重试
+	client, _ := NewRESTClientFor(&Config{Host: server.URL, Transport: &http.Transport{DisableKeepAlives: true}})
+	request := client.Get().Path("/").WatchRetries(3)
+	watcher, err := request.Watch(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var events []Event
+	for event := range watcher.ResultChan() {
+		events = append(events, event)
+	}
+	// 连续重连 3 次失败后返回错误
+	if n := atomic.LoadInt32(&connections); len(events) != 2 || events[1].Err == nil || n != 4 {
+		t.Fatalf("expected reconnects to stop after the retry budget, got %v after %d connections", events, n)
+	}
+	if request.headers.Get("Last-Event-ID") != "" {
+		t.Fatal("expected reconnects not to modify the caller's request headers")
+	}
+}
+
+func TestRequest_WatchReconnectUntilCanceled(t *testing.T) {
+	var connections int32
+	reconnected := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&connections, 1)
+		if n > 1 {
+			if n == 5 {
+				close(reconnected)
+			}
+			conn, _, _ := w.(http.Hijacker).Hijack()
+			_ = conn.Close()
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = fmt.Fprint(w, "retry: 1\nid: 1\ndata: a\n\n")
+	}))
+	defer server.Close()
+
+	// 默认配置下 MaxRetries 为 0, 仍然一直重连
+	client, _ := NewRESTClientFor(&Config{Host: server.URL, Transport: &http.Transport{DisableKeepAlives: true}})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	watcher, err := client.Get().Path("/").Watch(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		for range watcher.ResultChan() {
+		}
+	}()
+	select {
+	case <-reconnected:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("expected watch to keep reconnecting, got %d connections", atomic.LoadInt32(&connections))
+	}
+	watcher.Stop()
+}